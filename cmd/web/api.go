@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/Prateek2593/snippetbox/internal/validator"
+)
+
+// snippetInput holds the fields a client can send when creating or updating a snippet through the JSON API. the fields are pointers so that we can tell the difference between a field that was left out and one set to its zero value, which matters for PATCH requests
+// a snippet's files can be sent as a whole in Files, or for a snippet with a single file Content and Language can be used instead, which set those of the first file
+// the expiry can be sent as a whole number of days in Expires, or as a duration like "36h" or "2w" in ExpiresIn, which can also be "never". new snippets sent without either get the default expiry (see expiryBounds.apiDefault())
+type snippetInput struct {
+	Title            *string        `json:"title"`
+	Files            *[]models.File `json:"files"`
+	Content          *string        `json:"content"`
+	Language         *string        `json:"language"`
+	Visibility       *string        `json:"visibility"`
+	Expires          *int           `json:"expires"`
+	ExpiresIn        *string        `json:"expires_in"`
+	BurnAfterReading *bool          `json:"burn_after_reading"`
+}
+
+// applyExpiry overlays the expiry and burn after reading setting from the input onto the form. it returns an error if the client sent both ways of setting the expiry
+func (input snippetInput) applyExpiry(form *snippetCreateForm) error {
+	switch {
+	case input.Expires != nil && input.ExpiresIn != nil:
+		return errors.New("expires and expires_in cannot be sent together")
+	case input.Expires != nil:
+		// expires is a whole number of days, so a zero or negative one is reported here rather than as a bad duration like "-3d". renameErrors reports expires_custom as expires, and validateExpiry won't overwrite this message
+		if *input.Expires <= 0 {
+			form.AddFieldError("expires_custom", "This field must be a positive whole number of days")
+		}
+		form.Expires, form.ExpiresCustom = expiryCustom, fmt.Sprintf("%dd", *input.Expires)
+	case input.ExpiresIn != nil && *input.ExpiresIn == expiryNever:
+		form.Expires, form.ExpiresCustom = expiryNever, ""
+	case input.ExpiresIn != nil:
+		form.Expires, form.ExpiresCustom = expiryCustom, *input.ExpiresIn
+	}
+
+	if input.BurnAfterReading != nil {
+		form.BurnAfterReading = *input.BurnAfterReading
+	}
+	return nil
+}
+
+// applyFiles overlays the files, content and language from the input onto the form's files. it returns an error if the client sent both ways of setting the files
+func (input snippetInput) applyFiles(form *snippetCreateForm) error {
+	if input.Files != nil {
+		if input.Content != nil || input.Language != nil {
+			return errors.New("content and language cannot be sent along with files")
+		}
+		form.Files = fileForms(*input.Files)
+		return nil
+	}
+
+	if len(form.Files) == 0 {
+		form.Files = []snippetFileForm{{}}
+	}
+	if input.Content != nil {
+		form.Files[0].Content = *input.Content
+	}
+	if input.Language != nil {
+		form.Files[0].Language = *input.Language
+	}
+	return nil
+}
+
+// renameErrors moves errors from the names of the form fields to the names of the JSON fields the client actually sent. that is content and language for the first file when the client didn't send files, and expires or expires_in for the expiry
+func (input snippetInput) renameErrors(v *validator.Validator) {
+	renames := map[string]string{"expires_custom": "expires_in"}
+	if input.Expires != nil {
+		renames["expires_custom"] = "expires"
+	} else if input.ExpiresIn != nil {
+		renames["expires"] = "expires_in"
+	}
+	if input.Files == nil {
+		renames["files[0].content"] = "content"
+		renames["files[0].language"] = "language"
+	}
+
+	for from, to := range renames {
+		if message, ok := v.FieldErrors[from]; ok {
+			delete(v.FieldErrors, from)
+			v.FieldErrors[to] = message
+		}
+	}
+}
+
+// the apiSnippetList handler returns a page of the latest public snippets. the page, page_size and sort query string parameters choose which page and how the snippets are ordered
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+	filters := app.readFilters(r.URL.Query(), 20, &v)
+
+	if !v.Valid() {
+		app.apiValidationError(w, r, v)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Latest(filters)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippets": snippets, "metadata": metadata})
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": snippet})
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input snippetInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// copy the input into a snippetCreateForm, so that the API applies exactly the same validation rules as the HTML form. snippets are public, and get the default expiry, unless the client asks otherwise
+	form := snippetCreateForm{Visibility: models.VisibilityPublic}
+	form.Expires, form.ExpiresCustom = app.expiry.apiDefault()
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	err = input.applyFiles(&form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+	err = input.applyExpiry(&form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	form.validate(app.expiry)
+
+	if !form.Valid() {
+		input.renameErrors(&form.Validator)
+		app.apiValidationError(w, r, form.Validator)
+		return
+	}
+
+	id, slug, err := app.snippets.Insert(form.Title, form.modelFiles(), form.Visibility, form.expiry, form.BurnAfterReading, app.authenticatedUserID(r), 0)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.metrics.snippetsCreated.WithLabelValues("api").Inc()
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// set a Location header pointing at the new snippet, as is conventional for a 201 Created response
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%s", slug))
+	app.writeJSON(w, r, http.StatusCreated, envelope{"snippet": snippet})
+}
+
+// the apiSnippetUpdate handler applies a partial update, so any of the fields can be left out to keep their current value
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var input snippetInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// start from the current snippet and overlay the fields which were sent. like the edit form, the expiry is kept as it is unless a new one is sent
+	form := snippetCreateForm{
+		Title:            snippet.Title,
+		Files:            fileForms(snippet.Files),
+		Visibility:       snippet.Visibility,
+		Expires:          expiryKeep,
+		BurnAfterReading: snippet.BurnAfterReading,
+		Editing:          true,
+	}
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	err = input.applyFiles(&form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+	err = input.applyExpiry(&form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	form.validate(app.expiry)
+
+	if !form.Valid() {
+		input.renameErrors(&form.Validator)
+		app.apiValidationError(w, r, form.Validator)
+		return
+	}
+
+	// sending an empty language asks for it to be detected again
+	err = app.snippets.Update(snippet.ID, form.Title, form.modelFiles(), form.Visibility, form.expiry, form.BurnAfterReading)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	snippet, err = app.snippets.Get(snippet.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": snippet})
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiOwnedSnippet is the JSON API's version of ownedSnippet. it sends a JSON 404 or 403 response and returns false if the snippet doesnt exist or belongs to someone else
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusNotFound, "the requested snippet could not be found")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, r, http.StatusForbidden, "you do not have permission to modify this snippet")
+		return nil, false
+	}
+
+	return snippet, true
+}
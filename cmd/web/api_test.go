@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// apiResponse holds the parts of a JSON API response the tests look at
+type apiResponse struct {
+	Snippet *models.Snippet `json:"snippet"`
+	Error   struct {
+		Message     string            `json:"message"`
+		FieldErrors map[string]string `json:"field_errors"`
+	} `json:"error"`
+}
+
+func decodeAPIResponse(t *testing.T, body string) apiResponse {
+	t.Helper()
+
+	var rs apiResponse
+	if err := json.Unmarshal([]byte(body), &rs); err != nil {
+		t.Fatalf("couldn't decode the response %q: %s", body, err)
+	}
+	return rs
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	token, err := app.tokens.Insert(1, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		body            string
+		wantCode        int
+		wantExpiresIn   time.Duration // roughly how long until the new snippet expires, or models.Never
+		wantFieldErrors []string
+		wantFieldError  string // the message expected for the first of wantFieldErrors, if set
+		wantMessage     string
+	}{
+		{
+			name:          "Default expiry",
+			body:          `{"title": "Hello", "content": "world"}`,
+			wantCode:      http.StatusCreated,
+			wantExpiresIn: 365 * 24 * time.Hour,
+		},
+		{
+			name:          "Expires in days",
+			body:          `{"title": "Hello", "content": "world", "expires": 7}`,
+			wantCode:      http.StatusCreated,
+			wantExpiresIn: 7 * 24 * time.Hour,
+		},
+		{
+			name:          "Expires in a duration",
+			body:          `{"title": "Hello", "content": "world", "expires_in": "36h"}`,
+			wantCode:      http.StatusCreated,
+			wantExpiresIn: 36 * time.Hour,
+		},
+		{
+			name:          "Never expires",
+			body:          `{"title": "Hello", "content": "world", "expires_in": "never"}`,
+			wantCode:      http.StatusCreated,
+			wantExpiresIn: models.Never,
+		},
+		{
+			name:            "Missing title and content",
+			body:            `{}`,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantFieldErrors: []string{"title", "content"},
+		},
+		{
+			name:            "Too short",
+			body:            `{"title": "Hello", "content": "world", "expires_in": "1m"}`,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantFieldErrors: []string{"expires_in"},
+		},
+		{
+			name:            "Invalid duration",
+			body:            `{"title": "Hello", "content": "world", "expires_in": "soon"}`,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantFieldErrors: []string{"expires_in"},
+		},
+		{
+			name:            "Negative days",
+			body:            `{"title": "Hello", "content": "world", "expires": -3}`,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantFieldErrors: []string{"expires"},
+			wantFieldError:  "This field must be a positive whole number of days",
+		},
+		{
+			name:            "Zero days",
+			body:            `{"title": "Hello", "content": "world", "expires": 0}`,
+			wantCode:        http.StatusUnprocessableEntity,
+			wantFieldErrors: []string{"expires"},
+			wantFieldError:  "This field must be a positive whole number of days",
+		},
+		{
+			name:        "Both expiry fields",
+			body:        `{"title": "Hello", "content": "world", "expires": 7, "expires_in": "7d"}`,
+			wantCode:    http.StatusBadRequest,
+			wantMessage: "expires and expires_in cannot be sent together",
+		},
+		{
+			name:        "Unknown field",
+			body:        `{"title": "Hello", "content": "world", "colour": "red"}`,
+			wantCode:    http.StatusBadRequest,
+			wantMessage: `body contains unknown key "colour"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodPost, "/api/v1/snippets", bearer(token.Plaintext), tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d: %s", code, tt.wantCode, body)
+			}
+			rs := decodeAPIResponse(t, body)
+
+			if tt.wantCode == http.StatusCreated {
+				if got, want := header.Get("Location"), "/api/v1/snippets/"+rs.Snippet.Slug; got != want {
+					t.Errorf("got Location %q; want %q", got, want)
+				}
+				if tt.wantExpiresIn == models.Never {
+					if !rs.Snippet.NeverExpires() {
+						t.Errorf("got expiry %s; want never", rs.Snippet.Expires)
+					}
+				} else if d := time.Until(rs.Snippet.Expires) - tt.wantExpiresIn; d > time.Minute || d < -time.Minute {
+					t.Errorf("got expiry %s; want about %s from now", rs.Snippet.Expires, tt.wantExpiresIn)
+				}
+			}
+
+			for _, field := range tt.wantFieldErrors {
+				if _, ok := rs.Error.FieldErrors[field]; !ok {
+					t.Errorf("got field errors %v; want one for %q", rs.Error.FieldErrors, field)
+				}
+			}
+			if len(rs.Error.FieldErrors) != len(tt.wantFieldErrors) {
+				t.Errorf("got field errors %v; want only %v", rs.Error.FieldErrors, tt.wantFieldErrors)
+			}
+			if tt.wantFieldError != "" {
+				if got := rs.Error.FieldErrors[tt.wantFieldErrors[0]]; got != tt.wantFieldError {
+					t.Errorf("got %s error %q; want %q", tt.wantFieldErrors[0], got, tt.wantFieldError)
+				}
+			}
+			if tt.wantMessage != "" && !strings.Contains(rs.Error.Message, tt.wantMessage) {
+				t.Errorf("got message %q; want %q", rs.Error.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAPISnippetCreateDefaultExpiry(t *testing.T) {
+	tests := []struct {
+		name          string
+		bounds        expiryBounds
+		wantExpiresIn time.Duration
+	}{
+		{"Longest preset", expiryBounds{Min: 5 * time.Minute}, 365 * 24 * time.Hour},
+		{"Longest preset within the maximum", expiryBounds{Min: 5 * time.Minute, Max: 30 * 24 * time.Hour}, 7 * 24 * time.Hour},
+		{"No preset within the bounds", expiryBounds{Min: 5 * time.Minute, Max: 30 * time.Minute}, 30 * time.Minute},
+		{"No preset and no maximum", expiryBounds{Min: 400 * 24 * time.Hour}, models.Never},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.expiry = tt.bounds
+			ts := newTestServer(t, app.routes())
+
+			token, err := app.tokens.Insert(1, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _, body := ts.do(t, http.MethodPost, "/api/v1/snippets", bearer(token.Plaintext), `{"title": "Hello", "content": "world"}`)
+			if code != http.StatusCreated {
+				t.Fatalf("got status %d; want %d: %s", code, http.StatusCreated, body)
+			}
+			rs := decodeAPIResponse(t, body)
+
+			if tt.wantExpiresIn == models.Never {
+				if !rs.Snippet.NeverExpires() {
+					t.Errorf("got expiry %s; want never", rs.Snippet.Expires)
+				}
+			} else if d := time.Until(rs.Snippet.Expires) - tt.wantExpiresIn; d > time.Minute || d < -time.Minute {
+				t.Errorf("got expiry %s; want about %s from now", rs.Snippet.Expires, tt.wantExpiresIn)
+			}
+		})
+	}
+}
+
+func TestAPISnippetViewUpdateDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.users.Insert("Bob", "bob@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := app.tokens.Insert(1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := app.tokens.Insert(2, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, public, err := app.snippets.Insert("Public", []models.File{{Content: "hello"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, private, err := app.snippets.Insert("Private", []models.File{{Content: "secret"}}, models.VisibilityPrivate, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		body     string
+		wantCode int
+	}{
+		{"View public", http.MethodGet, "/api/v1/snippets/" + public, "", "", http.StatusOK},
+		{"View private anonymously", http.MethodGet, "/api/v1/snippets/" + private, "", "", http.StatusNotFound},
+		{"View private as another user", http.MethodGet, "/api/v1/snippets/" + private, bob.Plaintext, "", http.StatusNotFound},
+		{"View private as the owner", http.MethodGet, "/api/v1/snippets/" + private, alice.Plaintext, "", http.StatusOK},
+		{"View missing", http.MethodGet, "/api/v1/snippets/nosuchslug", "", "", http.StatusNotFound},
+		{"Update as another user", http.MethodPatch, "/api/v1/snippets/" + public, bob.Plaintext, `{"title": "Bob's"}`, http.StatusForbidden},
+		{"Update as the owner", http.MethodPatch, "/api/v1/snippets/" + public, alice.Plaintext, `{"title": "Renamed"}`, http.StatusOK},
+		{"Invalid update", http.MethodPatch, "/api/v1/snippets/" + public, alice.Plaintext, `{"title": ""}`, http.StatusUnprocessableEntity},
+		{"Delete as another user", http.MethodDelete, "/api/v1/snippets/" + public, bob.Plaintext, "", http.StatusForbidden},
+		{"Delete as the owner", http.MethodDelete, "/api/v1/snippets/" + public, alice.Plaintext, "", http.StatusNoContent},
+		{"View deleted", http.MethodGet, "/api/v1/snippets/" + public, "", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			if tt.token != "" {
+				header = bearer(tt.token)
+			}
+
+			code, _, body := ts.do(t, tt.method, tt.urlPath, header, tt.body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d: %s", code, tt.wantCode, body)
+			}
+		})
+	}
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	token, err := app.tokens.Insert(1, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const body = `{"title": "Hello", "content": "world"}`
+
+	// these requests are made before logging in, so there is no session
+	tests := []struct {
+		name             string
+		header           http.Header
+		wantCode         int
+		wantAuthenticate bool
+	}{
+		{"No credentials", nil, http.StatusUnauthorized, true},
+		{"Valid token", bearer(token.Plaintext), http.StatusCreated, false},
+		{"Invalid token", bearer("not-a-token"), http.StatusUnauthorized, true},
+		{"Wrong scheme", http.Header{"Authorization": {"Basic " + token.Plaintext}}, http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, rsBody := ts.do(t, http.MethodPost, "/api/v1/snippets", tt.header, body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d: %s", code, tt.wantCode, rsBody)
+			}
+			if got := header.Get("WWW-Authenticate") == "Bearer"; got != tt.wantAuthenticate {
+				t.Errorf("got WWW-Authenticate %q; want it set: %t", header.Get("WWW-Authenticate"), tt.wantAuthenticate)
+			}
+		})
+	}
+
+	// browser clients logged in with the session cookie have to send the CSRF token, because browsers send the cookie along with forged requests too
+	csrfToken := ts.login(t)
+
+	sessionTests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+	}{
+		{"Session without CSRF token", nil, http.StatusForbidden},
+		{"Session with invalid CSRF token", http.Header{"X-Csrf-Token": {"invalid"}}, http.StatusForbidden},
+		{"Session with CSRF token", http.Header{"X-Csrf-Token": {csrfToken}}, http.StatusCreated},
+	}
+
+	for _, tt := range sessionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, rsBody := ts.do(t, http.MethodPost, "/api/v1/snippets", tt.header, body)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d: %s", code, tt.wantCode, rsBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Prateek2593/snippetbox/ui"
+)
+
+// assets gives the application its templates and static files. normally they come from the copies embedded in the binary, but with -dev they are read from the ui directory on disk every time they are used, so that changes show up without rebuilding or restarting
+type assets struct {
+	fsys fs.FS // the ui files, with the templates under html/ and the static files under static/
+	dev  bool
+	// hashes maps each static file's path (like "css/main.css") to a hash of its contents. it is only filled in when the files are embedded, because they can't change while we run
+	hashes map[string]string
+}
+
+// newAssets returns the embedded assets, or with dev set the ones in the ./ui directory
+func newAssets(dev bool) (*assets, error) {
+	if dev {
+		return &assets{fsys: os.DirFS("ui"), dev: true}, nil
+	}
+
+	a := &assets{fsys: ui.Files, hashes: map[string]string{}}
+
+	// hash every static file once at startup, for staticURL() to put in the links
+	err := fs.WalkDir(a.fsys, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(a.fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		a.hashes[strings.TrimPrefix(name, "static/")] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// staticURL returns the URL of a static file, like "css/main.css". the URL includes a hash of the file's contents, so it changes whenever the file does, which means browsers can cache static files forever and still pick up a new version straight after a deploy
+func (a *assets) staticURL(name string) string {
+	url := "/static/" + name
+	if hash, ok := a.hashes[name]; ok {
+		url += "?v=" + hash
+	}
+	return url
+}
+
+// staticHandler serves the static files. a request for the current version of a file, as linked to by staticURL(), can be cached for a year. anything else, including every request with -dev, has to be checked with us before a cached copy is used
+func (a *assets) staticHandler() http.Handler {
+	static, err := fs.Sub(a.fsys, "static")
+	if err != nil {
+		// fs.Sub() only fails for an invalid directory name, and "static" isn't one
+		panic(err)
+	}
+	fileServer := http.StripPrefix("/static", http.FileServerFS(static))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/static/")
+
+		// embedded files have no modification time, so the hash doubles as an ETag which lets browsers revalidate their copy without downloading it again
+		if hash, ok := a.hashes[name]; ok {
+			w.Header().Set("ETag", `"`+hash+`"`)
+			if r.URL.Query().Get("v") == hash {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
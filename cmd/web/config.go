@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultCSP is the Content-Security-Policy header sent with every response unless the csp setting changes it
+const defaultCSP = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
+// envPrefix starts the name of the environment variable for each setting, e.g. SNIPPETBOX_REAP_INTERVAL for -reap-interval
+const envPrefix = "SNIPPETBOX_"
+
+// config holds all of the application's settings. every setting is a command line flag, and can also be given in a config file (JSON, YAML or TOML, using the flag names as keys) or with a SNIPPETBOX_* environment variable. command line flags take precedence over environment variables, which take precedence over the config file
+type config struct {
+	configFile string
+
+	addr        string
+	driver      string
+	dsn         string
+	memory      bool
+	autoMigrate bool
+
+	expiryMin time.Duration
+	expiryMax time.Duration
+
+	reapInterval time.Duration
+	reapBatch    int
+
+	tlsCert      string
+	tlsKey       string
+	tlsDev       bool
+	redirectAddr string
+
+	adminAddr       string
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	logFormat string
+	logLevel  string
+
+	dev bool
+
+	sessionLifetime time.Duration
+	bcryptCost      int
+	csp             string
+}
+
+// registerFlags defines a flag for each setting on fs, storing its value in cfg. the defaults given here are used for anything which isn't set some other way
+func (cfg *config) registerFlags(fs *flag.FlagSet) {
+	// define a flag for the config file. it can't be set in the config file itself, but can be set with the SNIPPETBOX_CONFIG environment variable
+	fs.StringVar(&cfg.configFile, "config", "", "Config file to read settings from (.json, .yaml, .yml or .toml)")
+
+	// define a new command line flag with name addr, a default value of ":4000" and some short help text explaining what the flag controls
+	fs.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
+
+	// define a new command line flag for the database driver, which also decides the SQL dialect used by the models and the session store
+	fs.StringVar(&cfg.driver, "driver", "mysql", "Database driver (mysql, postgres or sqlite)")
+
+	// define a new command line flag for the DSN string. if it is left empty we use the default DSN for the chosen driver
+	fs.StringVar(&cfg.dsn, "dsn", "", "Database data source name (defaults depend on -driver)")
+
+	// define a flag which runs the application against in-memory models and sessions instead of mysql. everything is lost when the process exits
+	fs.BoolVar(&cfg.memory, "memory", false, "Use in-memory storage instead of a database")
+
+	// define a flag which applies any pending migrations at startup. without it the server refuses to start if the database schema is behind
+	fs.BoolVar(&cfg.autoMigrate, "auto-migrate", false, "Apply pending database migrations at startup")
+
+	// define flags for the shortest and longest time that users can keep a snippet for. a maximum of 0 means there is no limit, which also lets users create snippets that never expire
+	fs.DurationVar(&cfg.expiryMin, "expiry-min", 5*time.Minute, "Shortest time a snippet can be kept for")
+	fs.DurationVar(&cfg.expiryMax, "expiry-max", 0, "Longest time a snippet can be kept for (0 for no limit, which allows snippets that never expire)")
+
+	// define flags for how often the reaper deletes expired snippets from the database, and how many it deletes in each statement. an interval of 0 turns the reaper off
+	fs.DurationVar(&cfg.reapInterval, "reap-interval", 10*time.Minute, "How often to delete expired snippets and sessions (0 to disable)")
+	fs.IntVar(&cfg.reapBatch, "reap-batch", 500, "Most expired snippets to delete in a single statement")
+
+	// define flags for serving HTTPS. either give the certificate and key files, or use -tls-dev to generate a self-signed certificate for local development. -redirect-addr starts a second, plain HTTP listener which sends browsers to the HTTPS server
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "", "TLS certificate file (PEM), to serve HTTPS")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "", "TLS private key file (PEM), to serve HTTPS")
+	fs.BoolVar(&cfg.tlsDev, "tls-dev", false, "Serve HTTPS with a self-signed certificate generated at startup, for development")
+	fs.StringVar(&cfg.redirectAddr, "redirect-addr", "", "HTTP network address which redirects to HTTPS (needs -tls-cert and -tls-key, or -tls-dev)")
+
+	// define a flag for the address of the admin listener, which serves the prometheus metrics. it is kept apart from the main server so that the metrics aren't public. an empty address turns it off
+	fs.StringVar(&cfg.adminAddr, "admin-addr", "localhost:4001", "HTTP network address for the admin listener, which serves /metrics (empty to disable)")
+
+	// define a flag for how long to wait for in-flight requests to finish when the server is asked to stop
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests to finish when shutting down")
+
+	// define a flag for how long to keep serving after we've been asked to shut down, with /readyz failing so that load balancers stop sending us new requests. it should be a little longer than the interval between their readiness probes
+	fs.DurationVar(&cfg.drainDelay, "drain-delay", 0, "How long to keep serving with /readyz failing before shutting down, so that load balancers can drain traffic")
+
+	// define flags for the format of the log output and the least severe level of message which is logged
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "Log output format (text or json)")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "Lowest level of log message to write (debug, info, warn or error)")
+
+	// define a flag which reads the templates and static files from the ./ui directory, reloading them on every request, instead of using the copies built into the binary. this is for working on the UI, so the server has to be started from the project root
+	fs.BoolVar(&cfg.dev, "dev", false, "Read templates and static files from ./ui on every request instead of the embedded copies, for development")
+
+	// define flags for how long users stay logged in, how much work goes into hashing their passwords and the Content-Security-Policy header
+	fs.DurationVar(&cfg.sessionLifetime, "session-lifetime", 12*time.Hour, "How long a session lasts before the user has to log in again")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", models.DefaultBcryptCost, fmt.Sprintf("bcrypt cost for hashing new passwords (%d to %d)", bcrypt.MinCost, bcrypt.MaxCost))
+	fs.StringVar(&cfg.csp, "csp", defaultCSP, "Content-Security-Policy header sent with every response")
+}
+
+// load fills in cfg from the config file, the environment and the command line args, in that order so that each one overrides the ones before. it returns an error naming the file, variable or flag which couldn't be used
+func (cfg *config) load(fs *flag.FlagSet, args []string) error {
+	// parse the command line once first, to find out whether it names a config file
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	configSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			configSet = true
+		}
+	})
+	if !configSet {
+		if path, ok := os.LookupEnv(envName("config")); ok {
+			cfg.configFile = path
+		}
+	}
+
+	if cfg.configFile != "" {
+		if err := cfg.loadFile(fs, cfg.configFile); err != nil {
+			return err
+		}
+	}
+
+	// an environment variable which is set but empty still counts, so that settings like admin-addr can be turned off
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// reading the file and the environment will have overwritten any settings which were also on the command line, so we parse it again to put them back
+	return fs.Parse(args)
+}
+
+// loadFile sets the flags named by the keys in the config file. the format is chosen by the file's extension. keys can use underscores instead of hyphens, so that reap_interval and reap-interval are the same
+func (cfg *config) loadFile(fs *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(b)))
+		dec.UseNumber() // so that whole numbers stay whole, rather than becoming floats like 5e+02
+		err = dec.Decode(&settings)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &settings)
+	case ".toml":
+		err = toml.Unmarshal(b, &settings)
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, use .json, .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	// go through the keys in order, so that errors are always reported in the same order
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		value := settings[key]
+		name := strings.ReplaceAll(key, "_", "-")
+		f := fs.Lookup(name)
+		if f == nil || name == "config" {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+
+		switch value.(type) {
+		case map[string]any, map[any]any, []any:
+			errs = append(errs, fmt.Errorf("config file %s: %s must be a single value", path, key))
+			continue
+		}
+
+		if err := f.Value.Set(fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: invalid value %v for %s: %v", path, value, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envName returns the name of the environment variable for the flag with the given name, e.g. SNIPPETBOX_REAP_INTERVAL for reap-interval
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// validate checks that the settings make sense together, and returns an error listing everything which doesn't
+func (cfg *config) validate() error {
+	var errs []error
+
+	if !cfg.memory {
+		if _, err := models.ParseDialect(cfg.driver); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if cfg.expiryMin <= 0 || cfg.expiryMax < 0 || (cfg.expiryMax != 0 && cfg.expiryMax < cfg.expiryMin) {
+		errs = append(errs, errors.New("expiry-min must be positive, and no longer than expiry-max"))
+	}
+
+	if cfg.reapInterval < 0 || cfg.reapBatch < 1 {
+		errs = append(errs, errors.New("reap-interval can't be negative, and reap-batch must be at least 1"))
+	}
+
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") || (cfg.tlsDev && cfg.tlsCert != "") {
+		errs = append(errs, errors.New("give both tls-cert and tls-key, or tls-dev, but not both"))
+	}
+	if cfg.redirectAddr != "" && !cfg.useTLS() {
+		errs = append(errs, errors.New("redirect-addr needs HTTPS to redirect to"))
+	}
+
+	if cfg.shutdownTimeout <= 0 || cfg.drainDelay < 0 {
+		errs = append(errs, errors.New("shutdown-timeout must be positive, and drain-delay can't be negative"))
+	}
+
+	if cfg.sessionLifetime <= 0 {
+		errs = append(errs, errors.New("session-lifetime must be positive"))
+	}
+	if cfg.bcryptCost < bcrypt.MinCost || cfg.bcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if strings.TrimSpace(cfg.csp) == "" {
+		errs = append(errs, errors.New("csp can't be empty"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// useTLS reports whether the server should serve HTTPS
+func (cfg *config) useTLS() bool {
+	return cfg.tlsDev || cfg.tlsCert != "" || cfg.tlsKey != ""
+}
+
+// runConfig runs the config subcommand. "config print [json|yaml|toml]" writes the effective settings to w, in a form which can be used as a config file. the database password is redacted
+func runConfig(cfg *config, fs *flag.FlagSet, args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] != "print" || len(args) > 2 {
+		return errors.New("usage: config print [json|yaml|toml]")
+	}
+	format := "json"
+	if len(args) == 2 {
+		format = args[1]
+	}
+
+	// the settings are keyed by flag name. flag.Getter gives us the typed value of each flag, so that numbers and booleans are written as such
+	settings := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		switch value := f.Value.(flag.Getter).Get().(type) {
+		case time.Duration:
+			settings[f.Name] = value.String()
+		default:
+			settings[f.Name] = value
+		}
+	})
+
+	// without a dsn the server connects to the default one for the driver, just like openDB() does, so that is the one we show. with -memory there is no database at all
+	dsn := cfg.dsn
+	if dsn == "" && !cfg.memory {
+		if dialect, err := models.ParseDialect(cfg.driver); err == nil {
+			dsn = defaultDSNs[dialect]
+		}
+	}
+	settings["dsn"] = redactDSN(cfg.driver, dsn)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false) // the & in DSNs shouldn't be escaped
+		return enc.Encode(settings)
+	case "yaml":
+		b, err := yaml.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "toml":
+		return toml.NewEncoder(w).Encode(settings)
+	default:
+		return fmt.Errorf("unsupported format %q, use json, yaml or toml", format)
+	}
+}
+
+// redactedPassword replaces the password in a redacted DSN. it is the same as the one url.URL.Redacted() uses
+const redactedPassword = "xxxxx"
+
+// postgresPassword matches the password in a postgres DSN written as key=value pairs, which may be quoted
+var postgresPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN returns the DSN with the password replaced, so that it can be shown safely. each driver has its own DSN format: mysql uses user:password@tcp(host)/dbname, postgres either a URL or key=value pairs, and sqlite a file name which has no password. if the DSN can't be parsed the whole thing is redacted, to be on the safe side
+func redactDSN(driver, dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+
+	switch dialect, _ := models.ParseDialect(driver); dialect {
+	case models.MySQL:
+		c, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return redactedPassword
+		}
+		if c.Passwd != "" {
+			c.Passwd = redactedPassword
+		}
+		return c.FormatDSN()
+	case models.Postgres:
+		if strings.Contains(dsn, "://") {
+			u, err := url.Parse(dsn)
+			if err != nil {
+				return redactedPassword
+			}
+			// the password can also be given as a query parameter
+			if q := u.Query(); q.Has("password") {
+				q.Set("password", redactedPassword)
+				u.RawQuery = q.Encode()
+			}
+			return u.Redacted()
+		}
+		return postgresPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+	case models.SQLite:
+		return dsn
+	default:
+		return redactedPassword
+	}
+}
@@ -3,3 +3,18 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// the authenticatedUserIDContextKey holds the id of the authenticated user, so that handlers can tell who owns what without going back to the session
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// the tokenAuthenticatedContextKey is set when the request was authenticated with an API token rather than the session cookie. such requests don't need a CSRF token
+const tokenAuthenticatedContextKey = contextKey("tokenAuthenticated")
+
+// the requestIDContextKey holds the random ID given to each request by the assignRequestID middleware, which ties together the log lines for a request
+const requestIDContextKey = contextKey("requestID")
+
+// the loggedUserContextKey holds the *loggedUser which the authentication middleware fills in for logRequest
+const loggedUserContextKey = contextKey("loggedUser")
+
+// the routePatternContextKey holds the *routePattern which the route handlers fill in for the instrument middleware
+const routePatternContextKey = contextKey("routePattern")
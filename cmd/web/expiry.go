@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// the values of the expires form field which aren't one of the presets
+const (
+	expiryNever  = "never"
+	expiryCustom = "custom" // the duration is in the expires_custom field
+	expiryKeep   = "keep"   // only offered on the edit form, and leaves the expiry as it is
+)
+
+// expiryPreset is one of the durations offered as a radio button on the snippet forms
+type expiryPreset struct {
+	Value    string
+	Label    string
+	Duration time.Duration
+}
+
+var expiryPresets = []expiryPreset{
+	{Value: "365d", Label: "One Year", Duration: 365 * 24 * time.Hour},
+	{Value: "7d", Label: "One Week", Duration: 7 * 24 * time.Hour},
+	{Value: "1d", Label: "One Day", Duration: 24 * time.Hour},
+	{Value: "1h", Label: "One Hour", Duration: time.Hour},
+}
+
+// expiryBounds holds the shortest and longest time a snippet can be kept for, which are set by the -expiry-min and -expiry-max flags. a Max of 0 means there is no limit, and only then can snippets be kept forever
+type expiryBounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// allows reports whether a snippet can be kept for d, which can be models.Never
+func (b expiryBounds) allows(d time.Duration) bool {
+	if d == models.Never {
+		return b.Max == 0
+	}
+	return d >= b.Min && (b.Max == 0 || d <= b.Max)
+}
+
+// presets returns the presets which are within the bounds, for showing on the forms
+func (b expiryBounds) presets() []expiryPreset {
+	var presets []expiryPreset
+	for _, p := range expiryPresets {
+		if b.allows(p.Duration) {
+			presets = append(presets, p)
+		}
+	}
+	return presets
+}
+
+// defaultValue returns the value of the expires field which is selected when a new snippet form is shown. this is the longest preset within the bounds, or a custom duration if none of them are
+func (b expiryBounds) defaultValue() string {
+	if presets := b.presets(); len(presets) > 0 {
+		return presets[0].Value
+	}
+	return expiryCustom
+}
+
+// apiDefault returns the expires and expires_custom values for a snippet created through the API without an expiry. that's the preset the forms select by default or, if none of the presets are within the bounds, the longest time allowed
+func (b expiryBounds) apiDefault() (string, string) {
+	switch {
+	case b.defaultValue() != expiryCustom:
+		return b.defaultValue(), ""
+	case b.Max == 0:
+		return expiryNever, ""
+	default:
+		return expiryCustom, b.Max.String()
+	}
+}
+
+// message returns the validation error for a duration outside the bounds
+func (b expiryBounds) message() string {
+	if b.Max == 0 {
+		return fmt.Sprintf("This field must be at least %s", formatExpiry(b.Min))
+	}
+	return fmt.Sprintf("This field must be between %s and %s", formatExpiry(b.Min), formatExpiry(b.Max))
+}
+
+// parseExpiry parses a duration like 90m, 36h, 10d or 2w. as well as the units understood by time.ParseDuration(), it accepts a whole number of days or weeks. the duration must be positive
+func parseExpiry(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	var d time.Duration
+	var err error
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		d, err = parseDays(n, 1)
+	} else if n, ok := strings.CutSuffix(s, "w"); ok {
+		d, err = parseDays(n, 7)
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, errors.New("expiry must be positive")
+	}
+	return d, nil
+}
+
+// parseDays parses a whole number of units, each of which is days long. numbers too big for a time.Duration are rejected
+func parseDays(s string, days int) (time.Duration, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n > int(models.Never/(time.Duration(days)*24*time.Hour)) {
+		return 0, errors.New("expiry is too long")
+	}
+	return time.Duration(n*days) * 24 * time.Hour, nil
+}
+
+// formatExpiry formats a duration for showing to users, in the largest unit which divides it exactly, like "7 days" or "90 minutes"
+func formatExpiry(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+		{"second", time.Second},
+	}
+
+	for _, u := range units {
+		if d >= u.size && d%u.size == 0 {
+			n := int64(d / u.size)
+			if n == 1 {
+				return fmt.Sprintf("1 %s", u.name)
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	return d.String()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"Minutes", "90m", 90 * time.Minute, false},
+		{"Hours", "36h", 36 * time.Hour, false},
+		{"Mixed units", "1h30m", 90 * time.Minute, false},
+		{"Days", "10d", 10 * 24 * time.Hour, false},
+		{"Weeks", "2w", 14 * 24 * time.Hour, false},
+		{"Surrounding spaces", " 7d ", 7 * 24 * time.Hour, false},
+		{"Empty", "", 0, true},
+		{"No unit", "10", 0, true},
+		{"Unknown unit", "10y", 0, true},
+		{"Fractional days", "1.5d", 0, true},
+		{"Zero", "0s", 0, true},
+		{"Zero days", "0d", 0, true},
+		{"Negative", "-1h", 0, true},
+		{"Negative days", "-1d", 0, true},
+		{"Too many days", strconv.Itoa(math.MaxInt) + "d", 0, true},
+		{"Too many weeks", "20000000w", 0, true},
+		{"Just too many days", strconv.Itoa(int(models.Never/(24*time.Hour))+1) + "d", 0, true},
+		{"Most days", strconv.Itoa(int(models.Never/(24*time.Hour))) + "d", models.Never / (24 * time.Hour) * (24 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s; want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %q; want %s", err, tt.want)
+			}
+			if got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{24 * time.Hour, "1 day"},
+		{7 * 24 * time.Hour, "7 days"},
+		{36 * time.Hour, "36 hours"},
+		{time.Hour, "1 hour"},
+		{90 * time.Minute, "90 minutes"},
+		{5 * time.Minute, "5 minutes"},
+		{90 * time.Second, "90 seconds"},
+		{1500 * time.Millisecond, "1.5s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatExpiry(tt.d); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiryBounds(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name           string
+		bounds         expiryBounds
+		wantAllowed    []time.Duration
+		wantDisallowed []time.Duration
+		wantPresets    []string
+		wantDefault    string
+		wantAPIExpires string
+		wantAPICustom  string
+		wantMessage    string
+	}{
+		{
+			name:           "Minimum only",
+			bounds:         expiryBounds{Min: 5 * time.Minute},
+			wantAllowed:    []time.Duration{5 * time.Minute, 400 * day, models.Never},
+			wantDisallowed: []time.Duration{time.Minute},
+			wantPresets:    []string{"365d", "7d", "1d", "1h"},
+			wantDefault:    "365d",
+			wantAPIExpires: "365d",
+			wantMessage:    "This field must be at least 5 minutes",
+		},
+		{
+			name:           "Minimum and maximum",
+			bounds:         expiryBounds{Min: 2 * time.Hour, Max: 30 * day},
+			wantAllowed:    []time.Duration{2 * time.Hour, 30 * day},
+			wantDisallowed: []time.Duration{time.Hour, 31 * day, models.Never},
+			wantPresets:    []string{"7d", "1d"},
+			wantDefault:    "7d",
+			wantAPIExpires: "7d",
+			wantMessage:    "This field must be between 2 hours and 30 days",
+		},
+		{
+			name:           "No preset within the bounds",
+			bounds:         expiryBounds{Min: 5 * time.Minute, Max: 30 * time.Minute},
+			wantAllowed:    []time.Duration{5 * time.Minute, 30 * time.Minute},
+			wantDisallowed: []time.Duration{time.Hour, models.Never},
+			wantDefault:    expiryCustom,
+			wantAPIExpires: expiryCustom,
+			wantAPICustom:  "30m0s",
+			wantMessage:    "This field must be between 5 minutes and 30 minutes",
+		},
+		{
+			name:           "No preset and no maximum",
+			bounds:         expiryBounds{Min: 400 * day},
+			wantAllowed:    []time.Duration{400 * day, models.Never},
+			wantDisallowed: []time.Duration{365 * day},
+			wantDefault:    expiryCustom,
+			wantAPIExpires: expiryNever,
+			wantMessage:    "This field must be at least 400 days",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, d := range tt.wantAllowed {
+				if !tt.bounds.allows(d) {
+					t.Errorf("got %s not allowed; want allowed", d)
+				}
+			}
+			for _, d := range tt.wantDisallowed {
+				if tt.bounds.allows(d) {
+					t.Errorf("got %s allowed; want not allowed", d)
+				}
+			}
+
+			var presets []string
+			for _, p := range tt.bounds.presets() {
+				presets = append(presets, p.Value)
+			}
+			if !slices.Equal(presets, tt.wantPresets) {
+				t.Errorf("got presets %q; want %q", presets, tt.wantPresets)
+			}
+
+			if got := tt.bounds.defaultValue(); got != tt.wantDefault {
+				t.Errorf("got default %q; want %q", got, tt.wantDefault)
+			}
+
+			expires, custom := tt.bounds.apiDefault()
+			if expires != tt.wantAPIExpires || custom != tt.wantAPICustom {
+				t.Errorf("got API default %q, %q; want %q, %q", expires, custom, tt.wantAPIExpires, tt.wantAPICustom)
+			}
+
+			// the custom duration the API defaults to has to parse, and be within the bounds
+			if custom != "" {
+				d, err := parseExpiry(custom)
+				if err != nil || !tt.bounds.allows(d) {
+					t.Errorf("got API default %q which parses to %s, %v; want a duration within the bounds", custom, d, err)
+				}
+			}
+
+			if got := tt.bounds.message(); got != tt.wantMessage {
+				t.Errorf("got message %q; want %q", got, tt.wantMessage)
+			}
+		})
+	}
+}
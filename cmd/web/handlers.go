@@ -1,12 +1,18 @@
 package main
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Prateek2593/snippetbox/internal/diff"
 	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/Prateek2593/snippetbox/internal/syntax"
 	"github.com/Prateek2593/snippetbox/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -26,9 +32,17 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 
-	snippets, err := app.snippets.Latest()
+	// the page, page_size and sort query string parameters choose which snippets the home page shows, 10 at a time and newest first by default
+	var v validator.Validator
+	filters := app.readFilters(r.URL.Query(), 10, &v)
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.Latest(filters)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -46,7 +60,7 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		// because the home handler function is now a method against application it can access its fields, including the error logger
 		// app.errorLog.Println(err.Error())
 		// http.Error(w, err.Error(), http.StatusInternalServerError)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -62,16 +76,18 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// app.errorLog.Println(err.Error())
 		// http.Error(w, err.Error(), http.StatusInternalServerError)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 	}
 	*/
 
 	// call the newTemplateData() helper to get a templateData struct containing the 'default' data(which for now is just the current year) and add the snippet slice to it
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
 
 	// pass the data to render() as normal
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
 	// w.Write([]byte("Hello from Snippetbox!"))
 }
 
@@ -81,27 +97,30 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// when httprouter is parsing a request, the values of any named parameters will be stored in the request contect, ParamsFromContext() function is used to retrieve a slice containing these parameter  names and values
 	params := httprouter.ParamsFromContext(r.Context())
 
-	// extract the value of id parameter from the query string and try to
-	// convert it to an interger using the strconv.Atoi() function. If it can't be converted to an integer, or the value is less than 1, we return a 404 page not fount response.
-	// we can then use the ByName() method to get the value of the "id" named parameter from the slice and validate it as normal
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		// http.NotFound(w, r)
-		app.notFound(w)
-		return
-	}
-
-	// use the SnippetModel objects Get method to retrieve the data for a specific record based on its ID. if no matching recored is found, return a 404 not found response
-	snippet, err := app.snippets.Get(id)
+	// use the lookupSnippet() helper to look the snippet up by the slug in the URL. if no matching record is found return a 404 not found response
+	snippet, err := app.lookupSnippet(params.ByName("slug"))
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// a snippet which burns after reading can be viewed by anyone with the link, once. everything else is subject to the usual checks, so a private snippet which belongs to someone else is reported as not found too
+	burn := snippet.BurnAfterReading && snippet.UserID != app.authenticatedUserID(r)
+	if !burn && !app.canView(r, snippet) {
+		app.notFound(w)
+		return
+	}
+
+	// if the snippet was found by an old numeric id, permanently redirect to its slug URL so that links get updated
+	if params.ByName("slug") != snippet.Slug {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusMovedPermanently)
+		return
+	}
+
 	// initialize a slice containing the paths to the view.tmpl file, plus the base layout and navigation partial that we made earlier
 	/*files := []string{
 		"./ui/html/base.tmpl",
@@ -112,7 +131,7 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// parse the template files
 	ts, err := template.ParseFiles(files...)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -124,7 +143,7 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// and then execute them, notice how we are passing in the snippet data (a models.Snippet struct) as final parameter
 	err = ts.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 	}
 
 	// use the fmt.Fprintf function to interpolate the id value with our response and write it to the http.ResponseWriter
@@ -138,15 +157,119 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// use the PopString() method to retrieve the value for the "flash" key. PopString also deletes the key and the value from session data, so it acts like a one time fetch. if there is no matching key in session data this will return the empty string
 	// flash := app.sessionManager.PopString(r.Context(), "flash")
 
+	// if the snippet is a fork, fetch the parent so that we can link to it. a parent which has expired, or which the user isn't allowed to see, is left out
+	var parent *models.Snippet
+	if snippet.ParentID != 0 {
+		parent, err = app.snippets.Get(snippet.ParentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		if parent != nil && !app.canView(r, parent) {
+			parent = nil
+		}
+	}
+
+	// fetch the public forks of the snippet, which are listed underneath it
+	forks, err := app.snippets.Forks(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// delete the snippet before showing it. Burn() only succeeds for one request, so if two people open the link at the same time only one of them gets to see the snippet, and the other gets a 404
+	if burn {
+		err = app.snippets.Burn(snippet.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
 	// call the newTemplateData() helper to get a templateData struct containing the 'default' data(which for now is just the current year) and add the snippet slice to it
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Parent = parent
+	data.Forks = forks
+	data.Burned = burn
 
 	// pass the flash data to the template
 	// data.Flash = flash
 
 	// pass the data to render() as normal
-	app.render(w, http.StatusOK, "view.tmpl", data)
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// the snippetRaw handler returns the content of a snippet as plain text, without any of the HTML around it, so that it can be viewed in the browser or fetched with tools like curl. the snippetDownload handler does the same, but asks the browser to save it as a file
+// by default they return the snippet's first file. the file query string parameter picks another one by its 1-based position
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	app.serveRaw(w, r, "inline")
+}
+
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	app.serveRaw(w, r, "attachment")
+}
+
+// serveRaw sends one file of the snippet named in the URL with the given Content-Disposition. the usual visibility and expiry rules apply, so a private or expired snippet is reported as not found
+func (app *application) serveRaw(w http.ResponseWriter, r *http.Request, disposition string) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var v validator.Validator
+	n := readInt(r.URL.Query().Get("file"), 1, &v, "file")
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if n < 1 || n > len(snippet.Files) {
+		app.notFound(w)
+		return
+	}
+	file := snippet.Files[n-1]
+
+	// the content only changes when a new revision is saved, so the latest revision's time is when it was last modified
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	modified := snippet.Created
+	if len(revisions) > 0 {
+		modified = revisions[0].Created
+	}
+
+	// files without a name are saved as <slug>-<position>.txt. mime.FormatMediaType() takes care of quoting the filename, and of encoding it if it isn't plain ascii
+	filename := file.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("%s-%d.txt", snippet.Slug, n)
+	}
+
+	// the ETag is a hash of everything in the response which can change, so it changes whenever the file is edited or renamed
+	hash := sha256.Sum256([]byte(file.Filename + "\x00" + file.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, hash[:16]))
+	// no-cache lets clients keep a copy as long as they check with us before using it. copies of snippets which aren't public must only be kept by the user's own browser
+	if snippet.Visibility == models.VisibilityPublic {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	// http.ServeContent() answers conditional requests using the ETag and Last-Modified headers, sending a 304 Not Modified response if the client's copy is still current. it also supports range requests
+	http.ServeContent(w, r, filename, modified, strings.NewReader(file.Content))
 }
 
 // add a new snippetCreate handler
@@ -155,19 +278,172 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 
 	// initialize a new createSnippetForm instance and pass it to the template. notice how this is also a great opportunity to set any default or initial values for the form ---  here we set the initial value for the snippet expiry to 365 days
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Files:      []snippetFileForm{{}},
+		Visibility: models.VisibilityPublic,
+		Expires:    app.expiry.defaultValue(),
+	}
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
+}
+
+// the snippetFork handler shows the create form pre-populated with a copy of an existing snippet, which the user can change before saving it as their own. the parent's slug goes along in a hidden field so that snippetCreatePost can record where the fork came from
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	parent, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = parent
+	data.Form = snippetCreateForm{
+		Title:      parent.Title,
+		Files:      fileForms(parent.Files),
+		Visibility: models.VisibilityPublic,
+		Expires:    app.expiry.defaultValue(),
+		Parent:     parent.Slug,
 	}
-	app.render(w, http.StatusOK, "create.tmpl", data)
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 // remove the explicit FieldErrors struct field and instead embed the Validator type, embedding this means that out snippetCreateForm "inherits" all the fields and methods of our Validator type
 // update our snippetCreateForm struct to include struct tags which tell the decoder how to map HTML form values into different struct fields.
+// a snippet holds one or more files, which the form sends as files[0].filename, files[0].content and so on. files are removed by ticking their remove box, and the "add file" button submits the form with add_file set to get another row, so that neither needs any javascript
+// expires is the value of one of the expiryPresets, or one of the expiryNever, expiryCustom or expiryKeep constants
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title            string            `form:"title"`
+	Files            []snippetFileForm `form:"files"`
+	Visibility       string            `form:"visibility"`
+	Expires          string            `form:"expires"`
+	ExpiresCustom    string            `form:"expires_custom"` // a duration like 90m or 3d, used when expires is "custom"
+	BurnAfterReading bool              `form:"burn_after_reading"`
+	Parent           string            `form:"parent"` // the slug of the snippet being forked, if this is a fork
+	AddFile          string            `form:"add_file"`
+	Editing          bool              `form:"-"` // set for the edit form, which can keep the current expiry
 	// FieldErrors map[string]string
 	validator.Validator `form:"-"` // "-" tells decoder to completely ignore a field during decoding
+
+	expiry time.Duration // the expiry chosen on the form, which validate() works out. 0 means keep the current expiry
+}
+
+type snippetFileForm struct {
+	Filename string `form:"filename"`
+	Language string `form:"language"` // left blank when the user wants us to detect the language
+	Content  string `form:"content"`
+	Remove   bool   `form:"remove"`
+}
+
+// fileForms converts a snippet's files into rows for the form
+func fileForms(files []models.File) []snippetFileForm {
+	forms := make([]snippetFileForm, len(files))
+	for i, f := range files {
+		forms[i] = snippetFileForm{Filename: f.Filename, Language: f.Language, Content: f.Content}
+	}
+	return forms
+}
+
+// editFiles drops the files which the user ticked to remove, and adds an empty file row if they pressed the "add file" button. it reports whether they pressed it, in which case the form should just be shown again rather than saved
+func (form *snippetCreateForm) editFiles() bool {
+	files := form.Files[:0]
+	for _, f := range form.Files {
+		if !f.Remove {
+			files = append(files, f)
+		}
+	}
+	form.Files = files
+
+	if form.AddFile == "" {
+		return false
+	}
+	if len(form.Files) < models.MaxFiles {
+		form.Files = append(form.Files, snippetFileForm{})
+	}
+	return true
+}
+
+// validate runs the checks shared by the create and edit snippet forms. the expiry must be within the bounds set by the administrator
+func (form *snippetCreateForm) validate(bounds expiryBounds) {
+	// because the Validator type is embedded by the snippetCreateForm struct, we can call checkField() directly on it to execute our validation checks. checkField() will add the provided key and error message to the FieldErrors map if the check does not evaluate to true.
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+	form.validateExpiry(bounds)
+	form.CheckField(!form.BurnAfterReading || form.Visibility == models.VisibilityUnlisted, "burn_after_reading", "Snippets which burn after reading must be unlisted")
+
+	// a form without any files gets an empty one, so that the user has a row to fill in and sees the usual error for blank content
+	if len(form.Files) == 0 {
+		form.Files = []snippetFileForm{{}}
+	}
+	form.CheckField(len(form.Files) <= models.MaxFiles, "files", fmt.Sprintf("A snippet cannot have more than %d files", models.MaxFiles))
+
+	// the errors for each file are keyed by the name of its form field, like files[0].content
+	names := map[string]bool{}
+	for i, f := range form.Files {
+		key := fmt.Sprintf("files[%d].", i)
+		form.CheckField(validator.MaxChars(f.Filename, 255), key+"filename", "This field cannot be more than 255 characters long")
+		form.CheckField(!strings.ContainsAny(f.Filename, `/\`), key+"filename", "This field cannot contain slashes")
+		form.CheckField(f.Filename == "" || !names[f.Filename], key+"filename", "Another file already has this name")
+		form.CheckField(f.Language == "" || validator.PermittedValue(f.Language, syntax.Names()...), key+"language", "This field must be one of the supported languages")
+		form.CheckField(validator.NotBlank(f.Content), key+"content", "This field cannot be blank")
+		names[f.Filename] = true
+	}
+}
+
+// validateExpiry works out the expiry chosen on the form and checks that it is allowed
+func (form *snippetCreateForm) validateExpiry(bounds expiryBounds) {
+	key := "expires"
+	form.expiry = 0
+
+	switch form.Expires {
+	case expiryKeep:
+		form.CheckField(form.Editing, key, "This field must be one of the listed options")
+		return
+	case expiryNever:
+		form.expiry = models.Never
+	case expiryCustom:
+		key = "expires_custom"
+		d, err := parseExpiry(form.ExpiresCustom)
+		if err != nil {
+			form.AddFieldError(key, "This field must be a duration like 90m, 12h, 3d or 2w")
+			return
+		}
+		form.expiry = d
+	default:
+		for _, p := range expiryPresets {
+			if p.Value == form.Expires {
+				form.expiry = p.Duration
+			}
+		}
+		if form.expiry == 0 {
+			form.AddFieldError(key, "This field must be one of the listed options")
+			return
+		}
+	}
+
+	if form.expiry == models.Never {
+		form.CheckField(bounds.allows(models.Never), key, fmt.Sprintf("Snippets must expire within %s", formatExpiry(bounds.Max)))
+	} else {
+		form.CheckField(bounds.allows(form.expiry), key, bounds.message())
+	}
+}
+
+// modelFiles returns the form's files for saving, filling in the language of any file where the user left it blank. the language is detected from the filename, or from the title if the file has no name. call it once the form is valid
+func (form *snippetCreateForm) modelFiles() []models.File {
+	files := make([]models.File, len(form.Files))
+	for i, f := range form.Files {
+		if f.Language == "" {
+			name := f.Filename
+			if name == "" {
+				name = form.Title
+			}
+			f.Language = syntax.Detect(name, f.Content)
+		}
+		files[i] = models.File{Filename: f.Filename, Language: f.Language, Content: f.Content}
+	}
+	return files
 }
 
 // Add a snippetCreate handler function
@@ -246,7 +522,7 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		if len(form.FieldErrors) > 0 {
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 			return
 		}
 	*/
@@ -267,30 +543,493 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// because the Validator type is embedded by the snippetCreateForm struct, we can call checkField() directly on it to execute our validation checks. checkField() will add the provided key and error message to the FieldErrors map if the check does not evaluate to true.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal to 1, 7, or 365")
+	// if the snippet is a fork, look up the parent so that we can record it. the parent might have been deleted, have expired or have been made private since the form was shown
+	var parent *models.Snippet
+	parentID := 0
+	if form.Parent != "" {
+		parent, err = app.findSnippet(r, form.Parent)
+		if err != nil {
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.serverError(w, r, err)
+				return
+			}
+			form.AddNonFieldErrors("The snippet you are forking is no longer available")
+		} else {
+			parentID = parent.ID
+		}
+	}
+
+	// if the user added or removed a file, show the form again with the new rows
+	if form.editFiles() {
+		data := app.newTemplateData(r)
+		data.Snippet = parent
+		data.Form = form
+		app.render(w, r, http.StatusOK, "create.tmpl", data)
+		return
+	}
+
+	form.validate(app.expiry)
 
 	// use the valid method to see if any of the checks failed. if they did, then re render the template passing in the form in same way as before
 	if !form.Valid() {
 		data := app.newTemplateData(r)
+		data.Snippet = parent
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// pass the id of the authenticated user so that they are recorded as the owner of the snippet
+	_, slug, err := app.snippets.Insert(form.Title, form.modelFiles(), form.Visibility, form.expiry, form.BurnAfterReading, app.authenticatedUserID(r), parentID)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
+	app.metrics.snippetsCreated.WithLabelValues("web").Inc()
 
 	// use the Put() method to add a string value("Snippet created successfully") and the corresponding key ("flash") to session data
 	app.sessionManager.Put(r.Context(), "flash", "Snippet created successfully")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
+}
+
+// snippetFromRequest fetches the snippet named by the slug parameter in the URL using findSnippet()
+func (app *application) snippetFromRequest(r *http.Request) (*models.Snippet, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	return app.findSnippet(r, params.ByName("slug"))
+}
+
+// findSnippet fetches the snippet with the given slug using lookupSnippet(), returning models.ErrNoRecord if there is no such snippet
+// snippets which the current user can't see, like private snippets which belong to someone else, are also reported as missing, so that nobody else can even tell that they exist
+func (app *application) findSnippet(r *http.Request, slug string) (*models.Snippet, error) {
+	snippet, err := app.lookupSnippet(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if !app.canView(r, snippet) {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// lookupSnippet fetches the snippet with the given slug, without checking whether the current user can see it
+// links made before snippets had slugs use the numeric id instead, so we fall back to that, but only for public snippets. ids are easy to guess, and unlisted snippets must only be reachable by their slug
+func (app *application) lookupSnippet(slug string) (*models.Snippet, error) {
+	snippet, err := app.snippets.GetBySlug(slug)
+	if errors.Is(err, models.ErrNoRecord) {
+		id, atoiErr := strconv.Atoi(slug)
+		if atoiErr != nil || id < 1 {
+			return nil, models.ErrNoRecord
+		}
+
+		snippet, err = app.snippets.Get(id)
+		if err == nil && snippet.Visibility != models.VisibilityPublic {
+			return nil, models.ErrNoRecord
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return snippet, nil
+}
+
+// ownedSnippet fetches the snippet named by the slug parameter in the URL and checks that it belongs to the current user. if anything goes wrong it sends the appropriate error response (404 if the snippet doesnt exist, 403 if it belongs to someone else) and returns false
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// the snippetEdit handler displays the edit form pre-populated with the current snippet
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetCreateForm{
+		Title:            snippet.Title,
+		Files:            fileForms(snippet.Files),
+		Visibility:       snippet.Visibility,
+		Expires:          expiryKeep,
+		BurnAfterReading: snippet.BurnAfterReading,
+		Editing:          true,
+	}
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	form := snippetCreateForm{Editing: true}
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.editFiles() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusOK, "edit.tmpl", data)
+		return
+	}
+
+	// the edit form uses exactly the same validation rules as the create form
+	form.validate(app.expiry)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.modelFiles(), form.Visibility, form.expiry, form.BurnAfterReading)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet updated successfully")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// the snippetDelete handler asks the owner to confirm that they really want to delete the snippet
+func (app *application) snippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	app.render(w, r, http.StatusOK, "delete.tmpl", data)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted successfully")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+// the snippetHistory handler lists every revision of a snippet, newest first, along with who made it and when
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+// revisionDiff holds the two revisions being compared on the diff page and the changes to each of their files. when the first revision of a snippet is compared with the one before it, From is an empty revision with a Number of 0
+type revisionDiff struct {
+	From  *models.Revision
+	To    *models.Revision
+	Files []fileDiff // only the files which changed
+	Split bool       // show the diff side by side rather than unified
+}
+
+// fileDiff holds the hunks of changes to one file. files are matched up by their position in the snippet, so OldName and NewName differ when a file was renamed, and one of them is blank when a file was added or removed
+type fileDiff struct {
+	Number  int // the 1-based position of the file, for files without a name
+	OldName string
+	NewName string
+	Hunks   []diff.Hunk
+}
+
+// diffFiles compares the files of two revisions position by position, with three lines of unchanged context around each change. files which are the same in both are left out
+func diffFiles(from, to []models.File) []fileDiff {
+	var diffs []fileDiff
+	for i := 0; i < max(len(from), len(to)); i++ {
+		var old, new models.File
+		if i < len(from) {
+			old = from[i]
+		}
+		if i < len(to) {
+			new = to[i]
+		}
+
+		d := fileDiff{
+			Number:  i + 1,
+			OldName: old.Filename,
+			NewName: new.Filename,
+			Hunks:   diff.Hunks(diff.Lines(old.Content, new.Content), 3),
+		}
+		if len(d.Hunks) > 0 || d.OldName != d.NewName {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// the snippetDiff handler shows the changes between two revisions of a snippet, which are chosen by the from and to query string parameters. to defaults to the latest revision and from to the one before to. the diff is unified unless the view parameter is "split", in which case it is shown side by side
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromRequest(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	qs := r.URL.Query()
+	var v validator.Validator
+	to := readInt(qs.Get("to"), revisions[0].Number, &v, "to")
+	from := readInt(qs.Get("from"), to-1, &v, "from")
+	if !v.Valid() || from < 0 || to < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	d := revisionDiff{From: &models.Revision{}, Split: qs.Get("view") == "split"}
+
+	d.To, err = app.snippets.Revision(snippet.ID, to)
+	if err == nil && from > 0 {
+		d.From, err = app.snippets.Revision(snippet.ID, from)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the diff is computed line by line for each file
+	d.Files = diffFiles(d.From.Files, d.To.Files)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = &d
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
+// the snippetRestorePost handler lets the owner of a snippet restore the title and files of an older revision. the restored version is saved as a new revision, so the history itself is never rewritten
+func (app *application) snippetRestorePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	number, err := strconv.Atoi(params.ByName("revision"))
+	if err != nil || number < 1 {
+		app.notFound(w)
+		return
+	}
+
+	revision, err := app.snippets.Revision(snippet.ID, number)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// the visibility, expiry and burn after reading setting aren't part of a revision, so they keep their current values
+	err = app.snippets.Update(snippet.ID, revision.Title, revision.Files, snippet.Visibility, 0, snippet.BurnAfterReading)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Revision %d restored successfully", number))
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// the search handler shows a page of snippets matching the q query string parameter, most relevant first
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	// the page is checked like it is for the snippet list, which also stops the offset it turns into from overflowing
+	var v validator.Validator
+	page := readInt(r.URL.Query().Get("page"), 1, &v, "page")
+	v.CheckField(page >= 1 && page <= 10_000_000, "page", "must be between 1 and 10 million")
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Page = page
+
+	// an empty query just shows the search page without any results
+	if query != "" {
+		var err error
+		data.Snippets, data.HasNextPage, err = app.snippets.Search(query, page)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+// the userSnippets handler lists the snippets owned by the current authenticated user
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "snippets.tmpl", data)
+}
+
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// the userSettings handler shows the settings page, where users manage their personal API tokens
+func (app *application) userSettings(w http.ResponseWriter, r *http.Request) {
+	app.renderSettings(w, r, http.StatusOK, tokenCreateForm{})
+}
+
+// renderSettings renders the settings page with the current user's tokens and the given token form
+func (app *application) renderSettings(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm) {
+	tokens, err := app.tokens.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.Form = form
+	// a newly created token is passed through the session once by tokenCreatePost, so PopString() makes sure it is never shown again
+	data.NewToken = app.sessionManager.PopString(r.Context(), "newToken")
+	app.render(w, r, status, "settings.tmpl", data)
+}
+
+func (app *application) tokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		app.renderSettings(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	token, err := app.tokens.Insert(app.authenticatedUserID(r), form.Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// we only store the hash of the token, so this is the one chance to show the plaintext to the user
+	app.sessionManager.Put(r.Context(), "newToken", token.Plaintext)
+	app.sessionManager.Put(r.Context(), "flash", "Token created. Copy it now, you won't be able to see it again")
+
+	http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+}
+
+func (app *application) tokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Revoke() only deletes the token if it belongs to the current user, so there's no need for a separate ownership check
+	err = app.tokens.Revoke(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked")
+
+	http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
 }
 
 type userSignupForm struct {
@@ -303,7 +1042,7 @@ type userSignupForm struct {
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -326,7 +1065,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
@@ -338,9 +1077,9 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -358,7 +1097,7 @@ type userLoginForm struct {
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl", data)
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -378,7 +1117,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
@@ -389,9 +1128,9 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 			form.AddNonFieldErrors("Email or passeword is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -399,7 +1138,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	// use the RenewToken() method on the current seesion to change the session id. its good practice to generate a new session id when the authentication state or privilege levels changes for the user
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -414,7 +1153,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// use the RenewToken() method on the current session to change the session id again
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
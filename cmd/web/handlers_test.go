@@ -0,0 +1,469 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	for range 12 {
+		if _, _, err := app.snippets.Insert("Gopher", []models.File{{Content: "go"}}, models.VisibilityPublic, time.Hour, false, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"No query", "/search", http.StatusOK, ""},
+		{"First page", "/search?q=gopher", http.StatusOK, "Gopher"},
+		{"Second page", "/search?q=gopher&page=2", http.StatusOK, "Gopher"},
+		{"Past the last page", "/search?q=gopher&page=3", http.StatusOK, ""},
+		{"Largest page", "/search?q=gopher&page=10000000", http.StatusOK, ""},
+		{"Page too big", "/search?q=gopher&page=10000001", http.StatusBadRequest, ""},
+		{"Page which overflows the offset", "/search?q=gopher&page=9223372036854775807", http.StatusBadRequest, ""},
+		{"Page 0", "/search?q=gopher&page=0", http.StatusBadRequest, ""},
+		{"Negative page", "/search?q=gopher&page=-1", http.StatusBadRequest, ""},
+		{"Page which isn't a number", "/search?q=gopher&page=two", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetViewBySlug(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, slug, err := app.snippets.Insert("An old silent pond", []models.File{{Content: "A frog jumps into the pond"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlistedID, unlisted, err := app.snippets.Insert("Unlisted", []models.File{{Content: "only with the link"}}, models.VisibilityUnlisted, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, private, err := app.snippets.Insert("Private", []models.File{{Content: "secret"}}, models.VisibilityPrivate, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{"Valid slug", "/snippet/view/" + slug, http.StatusOK, "A frog jumps into the pond", ""},
+		{"Unlisted slug", "/snippet/view/" + unlisted, http.StatusOK, "only with the link", ""},
+		{"Slug in another case", "/snippet/view/" + strings.ToUpper(slug), http.StatusNotFound, "", ""},
+		{"Unknown slug", "/snippet/view/0123456789", http.StatusNotFound, "", ""},
+		{"Empty slug", "/snippet/view/", http.StatusNotFound, "", ""},
+		{"Private snippet", "/snippet/view/" + private, http.StatusNotFound, "", ""},
+		// links made before snippets had slugs are redirected, but only for public snippets
+		{"Public snippet's id", "/snippet/view/" + strconv.Itoa(id), http.StatusMovedPermanently, "", "/snippet/view/" + slug},
+		{"Unlisted snippet's id", "/snippet/view/" + strconv.Itoa(unlistedID), http.StatusNotFound, "", ""},
+		{"Unknown id", "/snippet/view/999", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if got := header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("got Location %q; want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, slug, err := app.snippets.Insert("Two files", []models.File{{Content: "first file"}, {Filename: "main.go", Content: "package main"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unlisted, err := app.snippets.Insert("Unlisted", []models.File{{Content: "only with the link"}}, models.VisibilityUnlisted, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, private, err := app.snippets.Insert("Private", []models.File{{Content: "secret"}}, models.VisibilityPrivate, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expired, err := app.snippets.Insert("Expired", []models.File{{Content: "gone"}}, models.VisibilityPublic, -time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		urlPath          string
+		wantCode         int
+		wantBody         string
+		wantDisposition  string
+		wantCacheControl string
+	}{
+		{"First file", "/snippet/raw/" + slug, http.StatusOK, "first file", `inline; filename=` + slug + `-1.txt`, "no-cache"},
+		{"Second file", "/snippet/raw/" + slug + "?file=2", http.StatusOK, "package main", `inline; filename=main.go`, "no-cache"},
+		{"Download", "/snippet/download/" + slug + "?file=2", http.StatusOK, "package main", `attachment; filename=main.go`, "no-cache"},
+		{"Unlisted", "/snippet/raw/" + unlisted, http.StatusOK, "only with the link", `inline; filename=` + unlisted + `-1.txt`, "private, no-cache"},
+		{"File past the last", "/snippet/raw/" + slug + "?file=3", http.StatusNotFound, "", "", ""},
+		{"File 0", "/snippet/raw/" + slug + "?file=0", http.StatusNotFound, "", "", ""},
+		{"File which isn't a number", "/snippet/raw/" + slug + "?file=two", http.StatusBadRequest, "", "", ""},
+		{"Private", "/snippet/raw/" + private, http.StatusNotFound, "", "", ""},
+		{"Expired", "/snippet/download/" + expired, http.StatusNotFound, "", "", ""},
+		{"Unknown slug", "/snippet/raw/0123456789", http.StatusNotFound, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if body != tt.wantBody {
+				t.Errorf("got body %q; want %q", body, tt.wantBody)
+			}
+			if got := header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("got Content-Type %q; want %q", got, "text/plain; charset=utf-8")
+			}
+			if got := header.Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("got Content-Disposition %q; want %q", got, tt.wantDisposition)
+			}
+			if got := header.Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("got Cache-Control %q; want %q", got, tt.wantCacheControl)
+			}
+		})
+	}
+}
+
+func TestSnippetRawConditionalGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, slug, err := app.snippets.Insert("Conditional", []models.File{{Content: "version one"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, header, _ := ts.get(t, "/snippet/raw/"+slug)
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("got ETag %q and Last-Modified %q; want both set", etag, lastModified)
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+	}{
+		{"Matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"Other ETag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"Not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+		{"Modified since", http.Header{"If-Modified-Since": {time.Now().Add(-24 * time.Hour).UTC().Format(http.TimeFormat)}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.do(t, http.MethodGet, "/snippet/raw/"+slug, tt.header, "")
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	// editing the snippet changes its ETag, so the old copy is no longer current
+	err = app.snippets.Update(id, "Conditional", []models.File{{Content: "version two"}}, models.VisibilityPublic, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, body := ts.do(t, http.MethodGet, "/snippet/raw/"+slug, http.Header{"If-None-Match": {etag}}, "")
+	if code != http.StatusOK || body != "version two" {
+		t.Errorf("got status %d and body %q after an edit; want %d and %q", code, body, http.StatusOK, "version two")
+	}
+}
+
+func TestSnippetCreatePostExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	app.expiry = expiryBounds{Min: 5 * time.Minute, Max: 30 * 24 * time.Hour}
+	ts := newTestServer(t, app.routes())
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name          string
+		expires       string
+		expiresCustom string
+		wantCode      int
+		wantExpiresIn time.Duration // roughly how long until the new snippet expires
+	}{
+		{"Preset", "7d", "", http.StatusSeeOther, 7 * 24 * time.Hour},
+		{"Custom", expiryCustom, "90m", http.StatusSeeOther, 90 * time.Minute},
+		{"Custom in weeks", expiryCustom, "2w", http.StatusSeeOther, 14 * 24 * time.Hour},
+		{"Custom too short", expiryCustom, "1m", http.StatusUnprocessableEntity, 0},
+		{"Custom too long", expiryCustom, "31d", http.StatusUnprocessableEntity, 0},
+		{"Custom invalid", expiryCustom, "soon", http.StatusUnprocessableEntity, 0},
+		{"Custom missing", expiryCustom, "", http.StatusUnprocessableEntity, 0},
+		{"Preset outside the bounds", "365d", "", http.StatusUnprocessableEntity, 0},
+		{"Never with a maximum", expiryNever, "", http.StatusUnprocessableEntity, 0},
+		{"Unknown value", "3d", "", http.StatusUnprocessableEntity, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				"title":            {"Hello"},
+				"files[0].content": {"world"},
+				"visibility":       {models.VisibilityPublic},
+				"expires":          {tt.expires},
+				"expires_custom":   {tt.expiresCustom},
+				"csrf_token":       {csrfToken},
+			}
+			header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+			code, rsHeader, _ := ts.do(t, http.MethodPost, "/snippet/create", header, form.Encode())
+			if code != tt.wantCode {
+				t.Fatalf("got status %d; want %d", code, tt.wantCode)
+			}
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			snippet, err := app.snippets.GetBySlug(strings.TrimPrefix(rsHeader.Get("Location"), "/snippet/view/"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Until(snippet.Expires) - tt.wantExpiresIn; d > time.Minute || d < -time.Minute {
+				t.Errorf("got expiry %s; want about %s from now", snippet.Expires, tt.wantExpiresIn)
+			}
+		})
+	}
+}
+
+func TestSnippetViewBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, slug, err := app.snippets.Insert("Burner", []models.File{{Content: "read me once"}}, models.VisibilityPrivate, time.Hour, true, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// anyone with the link can see it the first time, even though it is private, and nobody can see it after that
+	code, _, body := ts.get(t, "/snippet/view/"+slug)
+	if code != http.StatusOK || !strings.Contains(body, "read me once") {
+		t.Fatalf("got status %d viewing the snippet the first time; want %d with its content", code, http.StatusOK)
+	}
+	code, _, _ = ts.get(t, "/snippet/view/"+slug)
+	if code != http.StatusNotFound {
+		t.Errorf("got status %d viewing the snippet again; want %d", code, http.StatusNotFound)
+	}
+	if _, err := app.snippets.GetBySlug(slug); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got error %v looking up the snippet; want %v", err, models.ErrNoRecord)
+	}
+}
+
+func TestSnippetViewBurnAfterReadingOwner(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t)
+
+	_, slug, err := app.snippets.Insert("Burner", []models.File{{Content: "read me once"}}, models.VisibilityPrivate, time.Hour, true, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the owner can look at their own snippet without burning it
+	for range 2 {
+		code, _, _ := ts.get(t, "/snippet/view/"+slug)
+		if code != http.StatusOK {
+			t.Fatalf("got status %d; want %d", code, http.StatusOK)
+		}
+	}
+}
+
+func TestLoginOverPlainHTTP(t *testing.T) {
+	// without any of the TLS flags we serve plain HTTP, and neither cookie can be marked secure or the browser would drop it
+	app := newTestApplication(t)
+	app.config.tlsDev = false
+	app.sessionManager.Cookie.Secure = app.config.useTLS()
+	ts := newPlainTestServer(t, app.routes())
+
+	// the test client's cookie jar sends secure cookies to 127.0.0.1 over plain HTTP anyway, the way browsers do for localhost, so logging in alone wouldn't catch a secure cookie. browsers drop them for any other host
+	_, header, _ := ts.get(t, "/user/login")
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		if cookie.Secure {
+			t.Errorf("got the %s cookie marked secure over plain HTTP", cookie.Name)
+		}
+	}
+
+	csrfToken := ts.login(t)
+
+	// once logged in, forms still pass the CSRF check
+	form := url.Values{
+		"title":            {"Hello"},
+		"files[0].content": {"world"},
+		"visibility":       {models.VisibilityPublic},
+		"expires":          {"7d"},
+		"csrf_token":       {csrfToken},
+	}
+	header = http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	code, _, _ := ts.do(t, http.MethodPost, "/snippet/create", header, form.Encode())
+	if code != http.StatusSeeOther {
+		t.Errorf("got status %d creating a snippet; want %d", code, http.StatusSeeOther)
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	csrfToken := ts.login(t)
+
+	if err := app.users.Insert("Bob", "bob@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	id, slug, err := app.snippets.Insert("Haiku", []models.File{{Content: "an old silent pond"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bobs, err := app.snippets.Insert("Bob's", []models.File{{Content: "mine"}}, models.VisibilityPublic, time.Hour, false, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(t *testing.T, urlPath string, form url.Values) int {
+		t.Helper()
+		form.Set("csrf_token", csrfToken)
+		header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+		code, _, _ := ts.do(t, http.MethodPost, urlPath, header, form.Encode())
+		return code
+	}
+	edit := func(t *testing.T, content string) int {
+		t.Helper()
+		return post(t, "/snippet/edit/"+slug, url.Values{
+			"title":            {"Haiku"},
+			"files[0].content": {content},
+			"visibility":       {models.VisibilityPublic},
+			"expires":          {expiryKeep},
+		})
+	}
+	revisions := func(t *testing.T) []*models.Revision {
+		t.Helper()
+		revisions, err := app.snippets.Revisions(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return revisions
+	}
+
+	t.Run("Edit adds a revision", func(t *testing.T) {
+		if code := edit(t, "a frog jumps in"); code != http.StatusSeeOther {
+			t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+		}
+		if got := revisions(t); len(got) != 2 || got[0].Number != 2 {
+			t.Errorf("got %d revisions; want 2, with revision 2 newest", len(got))
+		}
+
+		code, _, body := ts.get(t, "/snippet/view/"+slug+"/history")
+		if code != http.StatusOK || !strings.Contains(body, "#2 (current)") {
+			t.Errorf("got status %d; want %d with revision 2 as the current one", code, http.StatusOK)
+		}
+	})
+
+	t.Run("Unchanged save adds no revision", func(t *testing.T) {
+		if code := edit(t, "a frog jumps in"); code != http.StatusSeeOther {
+			t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+		}
+		if got := len(revisions(t)); got != 2 {
+			t.Errorf("got %d revisions; want 2", got)
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    string
+			wantCode int
+			wantBody string
+		}{
+			{"Latest changes", "", http.StatusOK, "a frog jumps in"},
+			{"Both revisions", "?from=1&to=2", http.StatusOK, "an old silent pond"},
+			{"First revision", "?from=0&to=1", http.StatusOK, "an old silent pond"},
+			{"From which isn't a number", "?from=x", http.StatusBadRequest, ""},
+			{"To which isn't a number", "?to=x", http.StatusBadRequest, ""},
+			{"Negative from", "?from=-1&to=2", http.StatusBadRequest, ""},
+			{"Missing revision", "?from=1&to=9", http.StatusNotFound, ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				code, _, body := ts.get(t, "/snippet/view/"+slug+"/diff"+tt.query)
+				if code != tt.wantCode {
+					t.Errorf("got status %d; want %d", code, tt.wantCode)
+				}
+				if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+					t.Errorf("want body to contain %q", tt.wantBody)
+				}
+			})
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			urlPath  string
+			wantCode int
+		}{
+			{"Someone else's snippet", "/snippet/restore/" + bobs + "/1", http.StatusForbidden},
+			{"Missing revision", "/snippet/restore/" + slug + "/9", http.StatusNotFound},
+			{"Revision which isn't a number", "/snippet/restore/" + slug + "/x", http.StatusNotFound},
+			{"Own snippet", "/snippet/restore/" + slug + "/1", http.StatusSeeOther},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if code := post(t, tt.urlPath, url.Values{}); code != tt.wantCode {
+					t.Errorf("got status %d; want %d", code, tt.wantCode)
+				}
+			})
+		}
+
+		// restoring saves the old version as a new revision, rather than rewriting the history
+		got := revisions(t)
+		if len(got) != 3 || got[0].Content != "an old silent pond" {
+			t.Errorf("got %d revisions, the newest with %q; want 3, the newest with %q", len(got), got[0].Content, "an old silent pond")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// readyCheckTimeout is how long each readiness check gets before it counts as failed. probes usually give up after a second or so themselves, so there's no point waiting longer
+const readyCheckTimeout = time.Second
+
+// checkResult is the outcome of one readiness check, as reported by /readyz
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthz answers liveness probes. if we can run a handler at all the process is alive, so it always says ok, even while shutting down. a failing liveness probe gets the process restarted, which isn't what we want just because the database is down
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	app.writeJSON(w, r, http.StatusOK, envelope{"status": "ok"})
+}
+
+// readyz answers readiness probes, which decide whether a load balancer sends us traffic. it checks that the database answers a ping, that the session store can be read and that the templates are loaded, and responds 503 if any of them fail or if we are shutting down, so that traffic is drained away before the server stops
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	checks := map[string]checkResult{}
+	ready := true
+
+	run := func(name string, check func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		defer cancel()
+
+		start := time.Now()
+		err := check(ctx)
+		result := checkResult{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			result.Status = "failing"
+			result.Error = err.Error()
+			ready = false
+		}
+		checks[name] = result
+	}
+
+	// there's no database to check when running with -memory
+	if app.db != nil {
+		run("database", app.db.PingContext)
+	}
+	run("sessions", app.checkSessionStore)
+	run("templates", app.checkTemplates)
+
+	status := "ok"
+	if app.shuttingDown.Load() {
+		status = "shutting down"
+		ready = false
+	} else if !ready {
+		status = "unavailable"
+	}
+
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	app.writeJSON(w, r, code, envelope{"status": status, "checks": checks})
+}
+
+// checkSessionStore looks up a session which can't exist. the store only returns an error if it couldn't be reached
+func (app *application) checkSessionStore(ctx context.Context) error {
+	const token = "readyz"
+
+	var err error
+	switch store := app.sessionManager.Store.(type) {
+	case scs.CtxStore:
+		_, _, err = store.FindCtx(ctx, token)
+	default:
+		_, _, err = store.Find(token)
+	}
+	return err
+}
+
+// checkTemplates makes sure the template cache was loaded with at least the home page. with -dev the cache isn't used, so it parses the templates on disk instead, the same way render() does
+// parsing can't be interrupted, so the context is checked before starting and again once it is done. that way a parse which takes longer than readyCheckTimeout still counts as failed
+func (app *application) checkTemplates(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cache := app.templateCache
+	if app.assets.dev {
+		var err error
+		cache, err = newTemplateCache(app.assets)
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := cache["home.tmpl"]; !ok {
+		return errors.New("the template cache is not loaded")
+	}
+	return nil
+}
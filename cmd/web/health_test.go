@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckTemplates(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		setup   func(app *application)
+		ctx     context.Context
+		wantErr bool
+	}{
+		{"Loaded", func(app *application) {}, context.Background(), false},
+		{"Not loaded", func(app *application) { app.templateCache = nil }, context.Background(), true},
+		{"Context canceled", func(app *application) {}, canceled, true},
+		// with -dev the templates on disk are parsed, so a missing or broken one is noticed even though the cache was loaded at startup. the tests run in cmd/web, two levels below the ui directory
+		{"Dev", func(app *application) { app.assets = &assets{fsys: os.DirFS("../../ui"), dev: true} }, context.Background(), false},
+		{"Dev without templates", func(app *application) { app.assets = &assets{fsys: fstest.MapFS{}, dev: true} }, context.Background(), true},
+		{"Dev with context canceled", func(app *application) { app.assets = &assets{fsys: os.DirFS("../../ui"), dev: true} }, canceled, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			tt.setup(app)
+
+			err := app.checkTemplates(tt.ctx)
+			if tt.wantErr && err == nil {
+				t.Error("got no error; want one")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("got error %q; want none", err)
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	var rs struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+
+	code, _, body := ts.get(t, "/readyz")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d; want %d: %s", code, http.StatusOK, body)
+	}
+	if err := json.Unmarshal([]byte(body), &rs); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sessions", "templates"} {
+		if got := rs.Checks[name].Status; got != "ok" {
+			t.Errorf("got %s check %q; want %q", name, got, "ok")
+		}
+	}
+
+	// once we start shutting down, the load balancer is told to stop sending us traffic
+	app.shuttingDown.Store(true)
+	code, _, _ = ts.get(t, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d while shutting down; want %d", code, http.StatusServiceUnavailable)
+	}
+}
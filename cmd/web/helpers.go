@@ -2,20 +2,36 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/Prateek2593/snippetbox/internal/validator"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
 )
 
-// the serverError helper writes an error message and stack trace to the errorLog, then sends a generic 500 Internal Server Error response to user
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+// the logServerError helper logs an unexpected error along with the request it happened in and a stack trace. the request id lets us find the request's own log line
+func (app *application) logServerError(r *http.Request, err error) {
+	app.logger.Error(err.Error(),
+		"request_id", requestID(r),
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"trace", string(debug.Stack()),
+	)
+}
+
+// the serverError helper logs the error and a stack trace, then sends a generic 500 Internal Server Error response to user
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logServerError(r, err)
 
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
@@ -30,12 +46,25 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	// retrieve the appropriate template set from the cache based on the page name (like 'home.tmpl). tf no entry exists in the cache with the provided name, then create a new error and call the serverError() helper method
-	ts, ok := app.templateCache[page]
+	// with -dev the templates are parsed again for every page, so that changes to them show up straight away
+	cache := app.templateCache
+	if app.assets.dev {
+		var err error
+		cache, err = newTemplateCache(app.assets)
+		if err != nil {
+			app.metrics.renderErrors.Inc()
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	ts, ok := cache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+		app.metrics.renderErrors.Inc()
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -45,7 +74,8 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	// write the template to the buffer, instead of straight to the http.ResponseWriter , if there's an error, call our serverError helper and return
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.metrics.renderErrors.Inc()
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -55,7 +85,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	//execute the template set and write the response body. again if there's any error we call the serverError
 	// err := ts.ExecuteTemplate(w, "base", data)
 	// if err != nil {
-	// 	app.serverError(w, err)
+	// 	app.serverError(w, r, err)
 	// }
 
 	// write the contents of the buffer to http.ResponseWriter, note:this is another time where we pass out http.ResponseWriter to a function that takes an io.Writer.
@@ -71,6 +101,11 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 		// add the authentication status to template data if exists
 		IsAuthenticated: app.IsAuthenticated(r),
 		CSRFToken:       nosurf.Token(r), // add the CSRFToken to template data
+		// add the authenticated user id to template data, or 0 if the user is not logged in
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		// the expiry options depend on the bounds set by the administrator
+		ExpiryPresets:  app.expiry.presets(),
+		CanNeverExpire: app.expiry.allows(models.Never),
 	}
 }
 
@@ -104,3 +139,147 @@ func (app *application) IsAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// return the id of the current authenticated user, or 0 if the request is not from an authenticated user
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// envelope is the top level object of every JSON API response, e.g. {"snippet": {...}} or {"error": {...}}
+type envelope map[string]any
+
+// the writeJSON helper encodes data as JSON and sends it with the given status code. like render(), it encodes into a buffer first so that an encoding error can still be turned into a proper error response
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data envelope) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+// the readJSON helper decodes a JSON request body into dst. the body is limited to 1MB, unknown fields are rejected, and the errors returned are safe to show to the client
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &invalidUnmarshalError):
+			// like decodePostForm(), passing an invalid destination is a bug in our code so we panic
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	// make sure the body only contained a single JSON value
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// the apiError helper sends a JSON error envelope with the given status code and message. it is the JSON API's equivalent of clientError
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	app.writeJSON(w, r, status, envelope{"error": envelope{"status": status, "message": message}})
+}
+
+// the apiServerError helper logs the error and stack trace like serverError, but sends a JSON 500 response
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logServerError(r, err)
+
+	app.apiError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+// the apiValidationError helper sends the field and non field errors collected by a validator.Validator as a 422 JSON response
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	fieldErrors := v.FieldErrors
+	if fieldErrors == nil {
+		fieldErrors = map[string]string{}
+	}
+	nonFieldErrors := v.NonFieldErrors
+	if nonFieldErrors == nil {
+		nonFieldErrors = []string{}
+	}
+
+	app.writeJSON(w, r, http.StatusUnprocessableEntity, envelope{"error": envelope{
+		"status":           http.StatusUnprocessableEntity,
+		"message":          "the request failed validation",
+		"field_errors":     fieldErrors,
+		"non_field_errors": nonFieldErrors,
+	}})
+}
+
+// the canView helper reports whether the current user is allowed to see a snippet. public and unlisted snippets can be seen by anyone, but private snippets only by their owner
+// snippets which burn after reading can also only be seen by their owner, apart from the one time they are shown by snippetView. otherwise their raw content, history or forks would give them away
+func (app *application) canView(r *http.Request, snippet *models.Snippet) bool {
+	if snippet.Visibility == models.VisibilityPrivate || snippet.BurnAfterReading {
+		return snippet.UserID == app.authenticatedUserID(r)
+	}
+	return true
+}
+
+// the readFilters helper reads the page, page_size and sort query string parameters into a models.Filters, using defaultPageSize when page_size is missing. any problems with the values are recorded in the validator
+func (app *application) readFilters(qs url.Values, defaultPageSize int, v *validator.Validator) models.Filters {
+	filters := models.Filters{
+		Page:     readInt(qs.Get("page"), 1, v, "page"),
+		PageSize: readInt(qs.Get("page_size"), defaultPageSize, v, "page_size"),
+		Sort:     qs.Get("sort"),
+	}
+	if filters.Sort == "" {
+		filters.Sort = "-created"
+	}
+
+	v.CheckField(filters.Page >= 1 && filters.Page <= 10_000_000, "page", "must be between 1 and 10 million")
+	v.CheckField(filters.PageSize >= 1 && filters.PageSize <= 100, "page_size", "must be between 1 and 100")
+	v.CheckField(validator.PermittedValue(filters.Sort, models.SortSafelist...), "sort", "invalid sort value")
+
+	return filters
+}
+
+// readInt parses an integer query string value, returning def if the value is empty. if it isn't a valid integer an error is recorded against key in the validator
+func readInt(value string, def int, v *validator.Validator, key string) int {
+	if value == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		v.AddFieldError(key, "must be an integer value")
+		return def
+	}
+	return i
+}
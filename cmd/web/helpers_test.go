@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/Prateek2593/snippetbox/internal/validator"
+)
+
+func TestReadFilters(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name       string
+		query      string
+		want       models.Filters
+		wantErrors []string
+	}{
+		{"Defaults", "", models.Filters{Page: 1, PageSize: 20, Sort: "-created"}, nil},
+		{"Everything set", "page=3&page_size=50&sort=title", models.Filters{Page: 3, PageSize: 50, Sort: "title"}, nil},
+		{"Largest page", "page=10000000&page_size=100", models.Filters{Page: 10_000_000, PageSize: 100, Sort: "-created"}, nil},
+		{"Page too big", "page=10000001", models.Filters{}, []string{"page"}},
+		{"Page 0", "page=0", models.Filters{}, []string{"page"}},
+		{"Page which isn't a number", "page=two", models.Filters{}, []string{"page"}},
+		{"Page size too big", "page_size=101", models.Filters{}, []string{"page_size"}},
+		{"Page size 0", "page_size=0", models.Filters{}, []string{"page_size"}},
+		{"Unknown sort", "sort=id", models.Filters{}, []string{"sort"}},
+		{"Everything wrong", "page=-1&page_size=x&sort=-id", models.Filters{}, []string{"page", "page_size", "sort"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v validator.Validator
+			filters := app.readFilters(qs, 20, &v)
+
+			var fields []string
+			for field := range v.FieldErrors {
+				fields = append(fields, field)
+			}
+			slices.Sort(fields)
+			if !slices.Equal(fields, tt.wantErrors) {
+				t.Errorf("got errors for %v; want %v", fields, tt.wantErrors)
+			}
+
+			if tt.wantErrors == nil && filters != tt.want {
+				t.Errorf("got %+v; want %+v", filters, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// newLogger returns a structured logger which writes to w in the given format, either "text" (key=value pairs, easy to read in a terminal) or "json" (one object per line, easy for log collectors to parse). messages below level, which is one of debug, info, warn or error, are thrown away
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be text or json", format)
+	}
+}
+
+// newRequestID returns a random ID for a request. 8 random bytes is plenty to tell requests apart in the logs, and short enough for users to copy when reporting a problem
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// return the ID of the current request, or an empty string if the assignRequestID middleware hasn't run
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// loggedUser is put in the request context by logRequest, and filled in by the authentication middleware once it knows who made the request. context values only travel down the middleware chain, so this is how the user id makes its way back up to the request log line
+type loggedUser struct {
+	id int
+}
+
+// setLoggedUser records that the request was made by the user with the given id, for the request log line
+func setLoggedUser(r *http.Request, id int) {
+	if u, ok := r.Context().Value(loggedUserContextKey).(*loggedUser); ok {
+		u.id = id
+	}
+}
+
+// responseRecorder wraps a http.ResponseWriter to remember the status code and how many bytes of body were written, for logging once the request is done
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends the status header first if the handler hasn't, which is what the wrapped ResponseWriter would do, so that we see a 200 status too
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the wrapped ResponseWriter, for things like flushing
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the prometheus metrics which the application records as it runs. they are registered in their own registry rather than the global one, and served on the admin listener by metricsHandler()
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	renderErrors    prometheus.Counter
+	snippetsCreated *prometheus.CounterVec
+
+	reaperRuns     prometheus.Counter
+	reaperDeleted  prometheus.Counter
+	reaperFailures prometheus.Counter
+	reaperLastRun  prometheus.Gauge
+}
+
+// newMetrics creates and registers the application's metrics. along with our own, it registers the standard go runtime and process metrics, the connection pool statistics from db.Stats() if db isn't nil, and the number of active sessions if countSessions isn't nil
+func newMetrics(db *sql.DB, countSessions func() (int, error)) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+
+		// requests are labelled with the route pattern (e.g. /snippet/view/:slug) rather than the path, otherwise every snippet would get metrics of its own
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "Number of HTTP requests handled, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_http_request_duration_seconds",
+			Help:    "How long HTTP requests took to handle, by method, route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		renderErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_template_render_errors_total",
+			Help: "Number of pages which failed to render.",
+		}),
+		snippetsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_snippets_created_total",
+			Help: "Number of snippets created, by whether they came from the web forms or the JSON API.",
+		}, []string{"source"}),
+
+		reaperRuns: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_reaper_runs_total",
+			Help: "Number of times the reaper has looked for expired snippets.",
+		}),
+		reaperDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_reaper_snippets_deleted_total",
+			Help: "Number of expired snippets deleted by the reaper.",
+		}),
+		reaperFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_reaper_failures_total",
+			Help: "Number of reaper runs which failed.",
+		}),
+		reaperLastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "snippetbox_reaper_last_run_timestamp_seconds",
+			Help: "Unix time at which the reaper last ran.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.renderErrors,
+		m.snippetsCreated,
+		m.reaperRuns,
+		m.reaperDeleted,
+		m.reaperFailures,
+		m.reaperLastRun,
+	)
+
+	// the connection pool gauges (go_sql_open_connections, go_sql_in_use_connections, go_sql_idle_connections and so on) are read from db.Stats() whenever the metrics are scraped
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "snippetbox"))
+	}
+
+	// the unexpired sessions are counted whenever the metrics are scraped, see newSessionCounter()
+	if countSessions != nil {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snippetbox_active_sessions",
+			Help: "Number of unexpired sessions.",
+		}, func() float64 {
+			n, err := countSessions()
+			if err != nil {
+				return 0
+			}
+			return float64(n)
+		}))
+	}
+
+	// start the counters for both sources at zero, so that they show up before the first snippet is created
+	m.snippetsCreated.WithLabelValues("web")
+	m.snippetsCreated.WithLabelValues("api")
+
+	return m
+}
+
+// metricsHandler returns the handler for the admin listener, which serves the metrics in the prometheus text format at /metrics
+func (app *application) metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{
+		ErrorLog: promErrorLogger{app},
+	}))
+	return mux
+}
+
+// promErrorLogger passes errors from the metrics handler on to our structured logger
+type promErrorLogger struct {
+	app *application
+}
+
+func (l promErrorLogger) Println(v ...any) {
+	l.app.logger.Error("failed to serve metrics", "error", fmt.Sprint(v...))
+}
+
+// routePattern is put in the request context by the instrument middleware, and filled in with the pattern of the matched route by withRoutePattern(). requests which don't match a route keep the "unmatched" pattern, so that 404s for random paths can't create any number of label values
+type routePattern struct {
+	pattern string
+}
+
+// withRoutePattern wraps the handler for a route, recording the route's pattern for the instrument middleware
+func withRoutePattern(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rp, ok := r.Context().Value(routePatternContextKey).(*routePattern); ok {
+			rp.pattern = pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// knownMethods are the HTTP methods which are used as metric labels as they are
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// instrument counts each request and records how long it took, labelled with the method, route pattern and status code
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		rp := &routePattern{pattern: "unmatched"}
+		ctx := context.WithValue(r.Context(), routePatternContextKey, rp)
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		// like the route, any method we don't know about gets lumped together
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+
+		status := strconv.Itoa(rw.status)
+		app.metrics.requests.WithLabelValues(method, rp.pattern, status).Inc()
+		app.metrics.requestDuration.WithLabelValues(method, rp.pattern, status).Observe(time.Since(start).Seconds())
+	})
+}
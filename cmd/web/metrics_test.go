@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/migrations"
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestSessionCounter(t *testing.T) {
+	// an in-memory sqlite database only lasts as long as its connection, so the pool must only have the one
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	if _, err := (&migrations.Migrator{DB: db, Dialect: models.SQLite}).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dialect models.Dialect
+		db      *sql.DB
+		store   scs.Store
+	}{
+		{"SQLite", models.SQLite, db, newSessionStore(models.SQLite, db, time.Hour)},
+		{"Memory", "", nil, memstore.NewWithCleanupInterval(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := newSessionCounter(tt.dialect, tt.db, tt.store)
+			if count == nil {
+				t.Fatal("got no session counter")
+			}
+
+			// two live sessions and one which has expired, but hasn't been cleaned up yet
+			sessions := map[string]time.Duration{"live1": time.Hour, "live2": time.Minute, "expired": -time.Minute}
+			for token, expiresIn := range sessions {
+				if err := tt.store.Commit(token, []byte("data"), time.Now().Add(expiresIn)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			n, err := count()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 2 {
+				t.Errorf("got %d sessions; want 2", n)
+			}
+		})
+	}
+}
@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/Prateek2593/snippetbox/internal/models"
 	"github.com/justinas/nosurf"
 )
 
-func secureHeaders(next http.Handler) http.Handler {
+// secureHeaders sets the security related headers on every response. the Content-Security-Policy comes from the csp setting
+func (app *application) secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", app.config.csp)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
@@ -20,11 +24,39 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// assignRequestID gives each request a random ID, which is stored in the request context for the log lines and sent back in the X-Request-ID header so that users can quote it when something goes wrong
+func assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := newRequestID()
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logRequest writes a log line for each request once it has been handled, with the status code, the size of the response body, how long it took and who made it (a user_id of 0 means nobody was logged in)
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		start := time.Now()
 
-		next.ServeHTTP(w, r)
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		user := &loggedUser{}
+		ctx := context.WithValue(r.Context(), loggedUserContextKey, user)
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		app.logger.Info("request",
+			"request_id", requestID(r),
+			"remote_addr", r.RemoteAddr,
+			"proto", r.Proto,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+			"user_id", user.id,
+		)
 	})
 }
 
@@ -37,7 +69,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// set a "Connection:close" header on the response
 				w.Header().Set("Connection", "close")
 				// call the app.serverError helper function to return a 500 internal server error
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -60,12 +92,86 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAPIAuthentication is the JSON API's version of requireAuthentication. instead of redirecting to the login page it sends a JSON 401 response, with a WWW-Authenticate header saying that an API token will do
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.IsAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, r, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiNoSurf is the JSON API's version of noSurf. browser clients authenticated by the session cookie must send the CSRF token in the X-CSRF-Token header, and a failed check gets a JSON 403 response rather than nosurf's plain text one
+func (app *application) apiNoSurf(next http.Handler) http.Handler {
+	csrfHandler := nosurf.New(next)
+	csrfHandler.SetBaseCookie(http.Cookie{
+		HttpOnly: true,
+		Secure:   app.config.useTLS(),
+		Path:     "/",
+	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.apiError(w, r, http.StatusForbidden, "missing or invalid CSRF token")
+	}))
+	// requests authenticated with an API token aren't sent automatically by browsers, so they can't be forged and don't need the CSRF check
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		isTokenAuthenticated, _ := r.Context().Value(tokenAuthenticatedContextKey).(bool)
+		return isTokenAuthenticated
+	})
+	return csrfHandler
+}
+
+// authenticateToken authenticates JSON API requests which carry an "Authorization: Bearer <token>" header. requests without the header are passed on unchanged so that the session cookie can be used instead, but an invalid token is rejected with a 401 response
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the response depends on the Authorization header, so caches must take it into account
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(authorizationHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, r, http.StatusUnauthorized, "invalid or missing authentication token")
+			return
+		}
+
+		id, err := app.tokens.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiError(w, r, http.StatusUnauthorized, "invalid or missing authentication token")
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		setLoggedUser(r, id)
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		ctx = context.WithValue(ctx, tokenAuthenticatedContextKey, true)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // Create a NoSurf Middleware function which uses a customized CSRF cookie with secure, path and HttpOnly attribures set
-func noSurf(next http.Handler) http.Handler {
+// like the session cookie, the CSRF cookie is only marked secure when we serve HTTPS. browsers won't store a secure cookie sent over plain HTTP, and every form would then fail the CSRF check
+func (app *application) noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   app.config.useTLS(),
 		Path:     "/",
 	})
 	return csrfHandler
@@ -73,8 +179,14 @@ func noSurf(next http.Handler) http.Handler {
 
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// if the request has already been authenticated with an API token there is nothing more to do
+		if app.IsAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// retrieve the authenticatedUserID value from the session using the GetInt() method. this will return the zero value for an int(0) if no "authenticatedUserID" value is in the session -- in which case we call the next handler in the chain as normal and return
-		id := app.sessionManager.GetInt(r.Context(), "authenticationUserID")
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
@@ -83,13 +195,16 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// otherwise, we check to see if the user with that ID exists in our database
 		exists, err := app.users.Exists(id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
 		// if a matching user is found, we know that the request is coming from an authenticated user who exists in our database, we create a new copy of the request(with an isAuthenticatedContextKey value of true in the request context) and assign it to r
+		// we also store the user id in the context so that handlers can record and check ownership
 		if exists {
+			setLoggedUser(r, id)
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
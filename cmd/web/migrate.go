@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/Prateek2593/snippetbox/internal/migrations"
+)
+
+// the runMigrate() function implements the "migrate up|down|status" subcommand, writing its progress to w
+func runMigrate(migrator *migrations.Migrator, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Fprintln(w, "no migrations to roll back")
+			return nil
+		}
+		fmt.Fprintf(w, "rolled back %04d_%s\n", m.Version, m.Name)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Fprintf(w, "%04d_%-30s applied %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(w, "%04d_%-30s pending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, must be up, down or status", args[0])
+	}
+
+	return nil
+}
+
+// the checkSchema() function makes sure that the database schema is up to date before the server starts. if there are pending migrations it either applies them (when autoMigrate is set) or returns an error telling the operator what to do
+func checkSchema(migrator *migrations.Migrator, autoMigrate bool, logger *slog.Logger) error {
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if !autoMigrate {
+		return fmt.Errorf("the database schema is behind by %d migration(s), run the migrate up command or start with -auto-migrate", len(pending))
+	}
+
+	applied, err := migrator.Up()
+	for _, m := range applied {
+		logger.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// reaper deletes expired snippets in the background. the queries already ignore expired snippets, so without it they would sit in the database forever
+// expired sessions are left to the scs session stores, which run their own cleanup on the same interval (see newSessionStore())
+type reaper struct {
+	snippets  models.SnippetModelInterface
+	interval  time.Duration
+	batchSize int
+	logger    *slog.Logger
+	metrics   *metrics
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// start runs the reaper in a new goroutine, once straight away and then every interval, until Stop() is called
+func (rp *reaper) start() {
+	rp.stop = make(chan struct{})
+	rp.wg.Add(1)
+
+	go func() {
+		defer rp.wg.Done()
+
+		ticker := time.NewTicker(rp.interval)
+		defer ticker.Stop()
+
+		for {
+			rp.run()
+
+			select {
+			case <-ticker.C:
+			case <-rp.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop tells the reaper to stop, and waits for it to finish the batch it is deleting if it is in the middle of a run
+func (rp *reaper) Stop() {
+	close(rp.stop)
+	rp.wg.Wait()
+}
+
+// run deletes expired snippets a batch at a time, until a batch comes back short which means there are none left. it gives up early if the reaper is stopped between batches
+func (rp *reaper) run() {
+	rp.metrics.reaperRuns.Inc()
+	rp.metrics.reaperLastRun.SetToCurrentTime()
+
+	total := 0
+	for {
+		n, err := rp.snippets.DeleteExpired(rp.batchSize)
+		total += n
+		rp.metrics.reaperDeleted.Add(float64(n))
+		if err != nil {
+			rp.metrics.reaperFailures.Inc()
+			rp.logger.Error("reaper failed to delete expired snippets", "error", err)
+			break
+		}
+		if n < rp.batchSize {
+			break
+		}
+
+		select {
+		case <-rp.stop:
+			rp.logger.Info("reaper stopped", "deleted", total)
+			return
+		default:
+		}
+	}
+
+	// most runs find nothing to do, so we only mention those when debugging
+	level := slog.LevelInfo
+	if total == 0 {
+		level = slog.LevelDebug
+	}
+	rp.logger.Log(context.Background(), level, "reaper deleted expired snippets", "deleted", total)
+}
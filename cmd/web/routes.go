@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -17,18 +19,39 @@ func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	// create a handler function which wraps our notFound() helper, and then assign it as the custom handler for 404 not found responses. you can also set a custom handler for 405 Method Not Allowed responses by setting router.MethodNotAllowed in same way
+	// requests for the JSON API get JSON error responses instead of plain text ones
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, r, http.StatusNotFound, "the requested resource could not be found")
+			return
+		}
 		app.notFound(w)
 	})
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("the %s method is not supported for this resource", r.Method))
+			return
+		}
+		app.clientError(w, http.StatusMethodNotAllowed)
+	})
+
+	// the handle() function registers a route with the router. it wraps the handler to record the route's pattern, which the instrument middleware uses to label its metrics (httprouter doesn't tell us which route matched)
+	handle := func(method, pattern string, handler http.Handler) {
+		router.Handler(method, pattern, withRoutePattern(pattern, handler))
+	}
 
-	// create a file server which serves files out of the "./ui/static" directory. note that the path given to the http.Dir function is relative to the project root
-	fileServer := http.FileServer(http.Dir("./ui/static/"))
+	// create a file server which serves the static files, which are embedded in the binary unless we're running with -dev (see assets.go)
+	fileServer := app.assets.staticHandler()
 
 	// use the mux.Handle() function to register the file server as the handler for all url paths that start with "/static/". for matching paths, we strip the "/static" prefix before the request reaches the file server
 	// mux.Handle("/static/", http.StripPrefix("/static", fileServer))
 
 	// update the pattern for the route for static files
-	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
+	handle(http.MethodGet, "/static/*filepath", fileServer)
+
+	// the health and readiness checks for load balancers and orchestrators. they don't need sessions or CSRF protection, so they skip the dynamic middleware chain
+	handle(http.MethodGet, "/healthz", http.HandlerFunc(app.healthz))
+	handle(http.MethodGet, "/readyz", http.HandlerFunc(app.readyz))
 
 	// mux.HandleFunc("/", app.home)
 	// mux.HandleFunc("/snippet/view", app.snippetView)
@@ -38,26 +61,55 @@ func (app *application) routes() http.Handler {
 	// unprotected application routes using the dynamic middleware chain
 	// use the nosurf middleware on all our dynamic routes
 	// add the authenticate() middleware to the chain
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.noSurf, app.authenticate)
 
 	// and then create routes using the appropriate methods, patterns and handlers
-	// update these routes to use the dynamic middleware chain followed by the appropriate handler function. note that because the alice ThenFunc() method returns a http.Handler(rather than a http.HandlerFunc) we also need to switch to registering the route using router.Handler method
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
-	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	// update these routes to use the dynamic middleware chain followed by the appropriate handler function. note that because the alice ThenFunc() method returns a http.Handler(rather than a http.HandlerFunc) we also need to switch to registering the route using router.Handler method, which handle() does for us
+	handle(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	handle(http.MethodGet, "/snippet/view/:slug", dynamic.ThenFunc(app.snippetView))
+	handle(http.MethodGet, "/snippet/view/:slug/history", dynamic.ThenFunc(app.snippetHistory))
+	handle(http.MethodGet, "/snippet/view/:slug/diff", dynamic.ThenFunc(app.snippetDiff))
+	handle(http.MethodGet, "/snippet/raw/:slug", dynamic.ThenFunc(app.snippetRaw))
+	handle(http.MethodGet, "/snippet/download/:slug", dynamic.ThenFunc(app.snippetDownload))
+	handle(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+	handle(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
+	handle(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
+	handle(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
+	handle(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
 
 	// protected(authenticated-only) application routes, using a new "protected" middleware chain which includes the requireAuthentication middleware
 	// because the protected middleware chain appends to dynamic chain, the noSurf middleware will also be used on the three routes below
 	protected := dynamic.Append(app.requireAuthentication)
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
-	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	handle(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
+	handle(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	handle(http.MethodGet, "/snippet/edit/:slug", protected.ThenFunc(app.snippetEdit))
+	handle(http.MethodPost, "/snippet/edit/:slug", protected.ThenFunc(app.snippetEditPost))
+	handle(http.MethodGet, "/snippet/delete/:slug", protected.ThenFunc(app.snippetDelete))
+	handle(http.MethodPost, "/snippet/delete/:slug", protected.ThenFunc(app.snippetDeletePost))
+	handle(http.MethodPost, "/snippet/restore/:slug/:revision", protected.ThenFunc(app.snippetRestorePost))
+	handle(http.MethodGet, "/snippet/fork/:slug", protected.ThenFunc(app.snippetFork))
+	handle(http.MethodGet, "/user/snippets", protected.ThenFunc(app.userSnippets))
+	handle(http.MethodGet, "/user/settings", protected.ThenFunc(app.userSettings))
+	handle(http.MethodPost, "/user/settings/tokens", protected.ThenFunc(app.tokenCreatePost))
+	handle(http.MethodPost, "/user/settings/tokens/revoke/:id", protected.ThenFunc(app.tokenRevokePost))
+	handle(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+
+	// the JSON API routes use their own middleware chain, which answers CSRF failures and unauthenticated requests with JSON instead of plain text and redirects
+	// scripts and CLIs can authenticate with an API token instead of the session cookie, in which case the CSRF check is skipped
+	apiAuthenticated := alice.New(app.sessionManager.LoadAndSave, app.authenticateToken, app.authenticate)
+	api := apiAuthenticated.Append(app.apiNoSurf)
+	handle(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	handle(http.MethodGet, "/api/v1/snippets/:slug", api.ThenFunc(app.apiSnippetView))
+
+	// the protected routes check that the request is authenticated before the CSRF check, so that clients which haven't authenticated get a 401 telling them to, rather than a 403 for the missing CSRF token
+	apiProtected := apiAuthenticated.Append(app.requireAPIAuthentication, app.apiNoSurf)
+	handle(http.MethodPost, "/api/v1/snippets", apiProtected.ThenFunc(app.apiSnippetCreate))
+	handle(http.MethodPatch, "/api/v1/snippets/:slug", apiProtected.ThenFunc(app.apiSnippetUpdate))
+	handle(http.MethodDelete, "/api/v1/snippets/:slug", apiProtected.ThenFunc(app.apiSnippetDelete))
 
 	// create a middleware chain containing our standard middlewares which will be used for every request our application receives
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// logRequest and instrument come before recoverPanic so that requests which panic are still logged and counted, with the 500 status they end up with
+	standard := alice.New(assignRequestID, app.logRequest, app.instrument, app.recoverPanic, app.secureHeaders)
 
 	// pass the servermux as the 'next' parameter to the secureHeaders middleware. because secureHeaders is just a function and the function returns a http.Handler we dont need to do anything else
 	// wrap the existing chain with logRequest middleware
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the server, along with the others (like the HTTP to HTTPS redirect and admin servers), until one of them fails or the process is sent SIGINT or SIGTERM. on a signal it makes the readiness check fail and keeps serving for drainDelay, so that load balancers notice and stop sending us requests. then it stops all the servers from accepting new connections and waits up to timeout for in-flight requests to finish before returning. it returns nil if the servers shut down cleanly
+func (app *application) serve(srv *http.Server, others []*http.Server, drainDelay time.Duration, timeout time.Duration) error {
+	servers := append([]*http.Server{srv}, others...)
+
+	// signal.Notify() needs a buffered channel, otherwise a signal sent while we aren't ready to receive it would be missed
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// serveErrors receives the error from each server which stops. it is buffered so that servers which stop once we've stopped listening don't block forever
+	serveErrors := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			serveErrors <- listenAndServe(s)
+		}()
+	}
+
+	app.logger.Info("starting server", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+
+	// once Shutdown() is called ListenAndServe() returns http.ErrServerClosed, so an error before that means something went wrong, like the address already being in use. we still shut down the other servers tidily before returning it
+	var serveErr error
+	select {
+	case serveErr = <-serveErrors:
+	case s := <-quit:
+		app.logger.Info("shutting down server", "signal", s.String(), "drain_delay", drainDelay)
+		app.shuttingDown.Store(true)
+
+		// a second signal while we are draining or waiting for requests to finish stops the process straight away, in the default way
+		signal.Stop(quit)
+		time.Sleep(drainDelay)
+	}
+	signal.Stop(quit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var shutdownErr error
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			shutdownErr = fmt.Errorf("requests still running after %s, shutting down anyway: %w", timeout, err)
+		}
+	}
+
+	if serveErr != nil {
+		return serveErr
+	}
+	if shutdownErr != nil {
+		return shutdownErr
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+	return nil
+}
+
+// listenAndServe starts the server, over TLS if it has a TLS config. the certificates are already in the config, so no files are passed to ListenAndServeTLS()
+func listenAndServe(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	// we only get http.ErrServerClosed when we've asked the server to shut down, so it isn't worth reporting
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
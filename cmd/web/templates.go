@@ -2,10 +2,15 @@ package main
 
 import (
 	"html/template"
-	"path/filepath"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Prateek2593/snippetbox/internal/models"
+	"github.com/Prateek2593/snippetbox/internal/syntax"
 )
 
 // define a templateData type to act as the holding structure for any dynamic data that we want to pass to our HTML template. at the moment it only contains one field, but will add more
@@ -13,10 +18,26 @@ type templateData struct {
 	CurrentYear     int
 	Snippet         *models.Snippet
 	Snippets        []*models.Snippet // include a snippets field in templateData struct
+	Tokens          []*models.Token
+	Parent          *models.Snippet   // the snippet that the current snippet was forked from
+	Forks           []*models.Snippet // the public forks of the current snippet
+	Revisions       []*models.Revision
+	Diff            *revisionDiff
+	Burned          bool   // the snippet burns after reading, and has been deleted now that it has been viewed
+	NewToken        string // the plaintext of a token that has just been created, which is only ever shown once
+	Query           string // the search query, which is shown in the nav search box and highlighted in the results
+	Page            int
+	HasNextPage     bool
+	Filters         models.Filters  // the paging and sorting options for a list of snippets
+	Metadata        models.Metadata // where the current page sits in the whole list, including the total number of snippets
 	Form            any
+	ExpiryPresets   []expiryPreset // the expiry presets offered on the snippet forms, and whether they also offer to never expire
+	CanNeverExpire  bool
 	Flash           string
 	IsAuthenticated bool   // add an IsAuthenticated field to templateData struct
 	CSRFToken       string // add a CSRF token field to templateData struct
+	// the id of the authenticated user, so that templates can show owner-only actions
+	AuthenticatedUserID int
 }
 
 // create a humanDate function which returns a nicely formatted string representation of time.Time object
@@ -24,17 +45,86 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// searchTermsRX returns a case insensitive regular expression which matches any of the terms in a search query, or nil if the query has no terms
+func searchTermsRX(query string) *regexp.Regexp {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	for i, t := range terms {
+		terms[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// create a highlight function which HTML escapes text and wraps every occurrence of the search query's terms in a <mark> element. because the text is escaped first it's safe to return it as template.HTML
+func highlight(text, query string) template.HTML {
+	rx := searchTermsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// create an excerpt function which returns roughly 200 characters of text around the first match for the search query, so that long snippets can be shown in the search results
+func excerpt(text, query string) string {
+	const width = 200
+
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+
+	start := 0
+	if rx := searchTermsRX(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			// convert the byte offset of the match into a rune offset, and start a little before it so the match has some context
+			start = max(utf8.RuneCountInString(text[:loc[0]])-width/4, 0)
+		}
+	}
+	end := min(start+width, len(runes))
+
+	result := string(runes[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
 // initialize a template.FuncMap object and store it in global variable. this is essentially a string-keyed map which acts as a lookup between the names of our custom template functions and functions themselves
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlight,
+	"excerpt":   excerpt,
+	"add":       func(a, b int) int { return a + b },
+	// the syntax highlighting functions come from the syntax package. highlightCode returns template.HTML, because chroma escapes the code as it formats it
+	"highlightCode": syntax.HTML,
+	"languages":     func() []syntax.Language { return syntax.Languages },
+	"languageLabel": syntax.Label,
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// newTemplateCache parses the page templates from the assets, which are either embedded in the binary or read from disk with -dev
+func newTemplateCache(a *assets) (map[string]*template.Template, error) {
 	// initialize a new map to act as a cache
 	cache := map[string]*template.Template{}
 
-	// use the filepath.GLob() function to get a slice of all filepaths that match the pattern "./ui/html/pages/*.tmpl". this will essentially gives us a slice of all filepaths for our application 'page' templates, like:[ui/html/pages/home.tmpl]
-	pages, err := filepath.Glob("./ui/html/pages/*.tmpl")
+	// use the fs.Glob() function to get a slice of all filepaths in the assets that match the pattern "html/pages/*.tmpl". this will essentially gives us a slice of all filepaths for our application 'page' templates, like:[html/pages/home.tmpl]
+	pages, err := fs.Glob(a.fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +132,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	// loop through the page filepaths one by one
 	for _, page := range pages {
 		// extract the name of the file without the extension (e.g., "home") using filepath.Base() function and store it in the name variable. this will be used as the key for our cache map.  e.g., (home.tmpl)
-		name := filepath.Base(page)
+		name := path.Base(page)
 
 		// create a slice containing the file path for our base template, any partial and the page
 		/*files := []string{
@@ -63,19 +153,20 @@ func newTemplateCache() (map[string]*template.Template, error) {
 
 		// parse the base template file into template set
 		// the template.FuncMap must be registered with the template set before you call the ParseFiles(). this means we have to use template.New() to create an empty template set, use the Funcs() method to register the template.FuncMap() and then parse the file as normal
-		ts, err := template.New(name).Funcs(functions).ParseFiles("./ui/html/base.tmpl")
+		// the static function links to a static file with its content hash, so that it depends on the assets and is registered alongside the global functions
+		ts, err := template.New(name).Funcs(functions).Funcs(template.FuncMap{"static": a.staticURL}).ParseFS(a.fsys, "html/base.tmpl")
 		if err != nil {
 			return nil, err
 		}
 
-		// call ParseGlob() *on this template set* to add any partials
-		ts, err = ts.ParseGlob("./ui/html/partials/*.tmpl")
+		// call ParseFS() *on this template set* to add any partials
+		ts, err = ts.ParseFS(a.fsys, "html/partials/*.tmpl")
 		if err != nil {
 			return nil, err
 		}
 
-		// call the ParseFiles() *on this template set* to add the page template
-		ts, err = ts.ParseFiles(page)
+		// call the ParseFS() *on this template set* to add the page template
+		ts, err = ts.ParseFS(a.fsys, page)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models/memory"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+)
+
+// newTestApplication returns an application backed by the in-memory models, with a user (id 1, alice@example.com) who can log in with the password "pa$$word"
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	assets, err := newAssets(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateCache, err := newTemplateCache(assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := memory.NewUserModel()
+	users.BcryptCost = 4 // bcrypt.MinCost, so that the tests don't spend their time hashing
+	if err := users.Insert("Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the test server serves HTTPS, so the cookies are secure just like they are when main() is given the TLS flags
+	cfg := &config{csp: defaultCSP, tlsDev: true}
+
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = cfg.useTLS()
+
+	return &application{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config:         cfg,
+		snippets:       memory.NewSnippetModel(users),
+		users:          users,
+		tokens:         memory.NewTokenModel(),
+		assets:         assets,
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+		expiry:         expiryBounds{Min: 5 * time.Minute},
+		metrics:        newMetrics(nil, newSessionCounter("", nil, sessionManager.Store)),
+	}
+}
+
+// testServer is a test server running the application's routes, over HTTPS unless it was made with newPlainTestServer(), with a client which keeps cookies but doesn't follow redirects
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+	return startTestServer(t, httptest.NewTLSServer(h))
+}
+
+// newPlainTestServer is like newTestServer, but serves plain HTTP, like the application does when it isn't given any of the TLS flags
+func newPlainTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+	return startTestServer(t, httptest.NewServer(h))
+}
+
+func startTestServer(t *testing.T, ts *httptest.Server) *testServer {
+	t.Helper()
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// do sends a request to the test server and returns the status code, headers and body of the response
+func (ts *testServer) do(t *testing.T, method, urlPath string, header http.Header, body string) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(b)
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, urlPath, nil, "")
+}
+
+// bearer returns the headers for a JSON API request authenticated with the token
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// csrfTokenRX matches the CSRF token in the hidden field of a HTML form
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
+
+// extractCSRFToken returns the CSRF token from a page with a form on it
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+	return html.UnescapeString(matches[1])
+}
+
+// login logs in as the user the test application is created with, and returns the CSRF token for the session
+func (ts *testServer) login(t *testing.T) string {
+	t.Helper()
+
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{"email": {"alice@example.com"}, "password": {"pa$$word"}, "csrf_token": {csrfToken}}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	code, _, _ := ts.do(t, http.MethodPost, "/user/login", header, form.Encode())
+	if code != http.StatusSeeOther {
+		t.Fatalf("got status %d logging in; want %d", code, http.StatusSeeOther)
+	}
+
+	// the session token is renewed when logging in, but the CSRF token stays the same
+	return csrfToken
+}
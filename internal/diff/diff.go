@@ -0,0 +1,328 @@
+// Package diff computes line by line differences between two texts, using the algorithm from Eugene Myers' paper "An O(ND) Difference Algorithm and Its Variations", and groups them into hunks for showing as a unified or side-by-side diff
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op says what happened to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns the name of the operation, which is handy as a CSS class
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// Line is a single line of a diff. OldNumber and NewNumber are the 1-based line numbers in the old and new texts, and are 0 for a line which doesnt appear in that text
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// Prefix returns the character which starts the line in a unified diff
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// maxEdits limits how much work Lines() does. the algorithm needs memory proportional to the square of the number of edits, so if two texts are more different than this we give up and report every old line as deleted and every new line as inserted
+const maxEdits = 1000
+
+// Lines returns the lines of a diff from old to new. windows line endings are treated the same as unix ones, because browsers submit textareas with \r\n
+func Lines(old, new string) []Line {
+	a, b := split(old), split(new)
+
+	// lines at the start and end which haven't changed don't need to go through the algorithm at all
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, Equal)
+	}
+
+	// walk through the edit script, numbering the lines as we go
+	lines := make([]Line, 0, len(ops))
+	x, y := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			lines = append(lines, Line{Op: Equal, Text: a[x], OldNumber: x + 1, NewNumber: y + 1})
+			x++
+			y++
+		case Delete:
+			lines = append(lines, Line{Op: Delete, Text: a[x], OldNumber: x + 1})
+			x++
+		case Insert:
+			lines = append(lines, Line{Op: Insert, Text: b[y], NewNumber: y + 1})
+			y++
+		}
+	}
+
+	return lines
+}
+
+// split breaks a text into lines. a trailing newline doesnt start another line, and an empty text has no lines at all
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// myers returns the shortest edit script which turns a into b. it follows the furthest reaching path along each diagonal k = x - y for an increasing number of edits d, keeping a copy of the endpoints from every round so that the path can be traced back afterwards
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(n, m)
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	for d := 0; d <= offset; d++ {
+		if d > maxEdits {
+			return replaceAll(n, m)
+		}
+
+		// only diagonals -d to d can have been reached so far, so that is all we need to remember for this round
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down from diagonal k+1, which inserts a line from b
+			} else {
+				x = v[offset+k-1] + 1 // move right from diagonal k-1, which deletes a line from a
+			}
+			y := x - k
+
+			// follow the diagonal for as long as the lines match
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+
+	return replaceAll(n, m)
+}
+
+// backtrack follows the path found by myers() back from the end, and returns the edit script in the right order
+func backtrack(trace [][]int, n, m int) []Op {
+	var ops []Op
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds diagonals -d to d+1, starting at index 0
+		v := func(k int) int { return trace[d][k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Equal)
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Insert)
+			} else {
+				ops = append(ops, Delete)
+			}
+			x, y = prevX, prevY
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll returns an edit script which deletes all n lines of a and inserts all m lines of b
+func replaceAll(n, m int) []Op {
+	ops := make([]Op, 0, n+m)
+	for i := 0; i < n; i++ {
+		ops = append(ops, Delete)
+	}
+	for i := 0; i < m; i++ {
+		ops = append(ops, Insert)
+	}
+	return ops
+}
+
+// Changed reports whether any of the lines were inserted or deleted
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunk is a run of changed lines along with some unchanged lines around them for context
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the hunk's header in the format used by unified diffs, e.g. "@@ -1,4 +1,5 @@"
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Hunks groups the changes in a diff into hunks with up to context unchanged lines either side. changes which are close enough for their context to overlap share a hunk
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// find the end of this hunk, carrying on past runs of unchanged lines which are short enough to be context for both sides
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+
+		start := max(i-context, 0)
+		stop := min(end+context+1, len(lines))
+
+		h := Hunk{Lines: lines[start:stop]}
+		oldBefore, newBefore := 0, 0
+		for _, l := range lines[:start] {
+			if l.Op != Insert {
+				oldBefore++
+			}
+			if l.Op != Delete {
+				newBefore++
+			}
+		}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+
+		// by convention a hunk which has no lines on one side gives the number of the line before it for that side
+		h.OldStart, h.NewStart = oldBefore, newBefore
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+
+		hunks = append(hunks, h)
+		i = stop
+	}
+
+	return hunks
+}
+
+// Row is one row of a side-by-side diff. Left is the line from the old text and Right the line from the new text, and either can be nil when a line was only deleted or only inserted
+type Row struct {
+	Left  *Line
+	Right *Line
+}
+
+// Rows lays the hunk's lines out side by side. unchanged lines appear on both sides, and each run of deleted lines is lined up against the inserted lines which follow it
+func (h Hunk) Rows() []Row {
+	var rows []Row
+	lines := h.Lines
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Left: &lines[i], Right: &lines[i]})
+			i++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Op == Delete; i++ {
+			deleted = append(deleted, &lines[i])
+		}
+		for ; i < len(lines) && lines[i].Op == Insert; i++ {
+			inserted = append(inserted, &lines[i])
+		}
+
+		for j := 0; j < max(len(deleted), len(inserted)); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Left = deleted[j]
+			}
+			if j < len(inserted) {
+				row.Right = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,227 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// unified formats lines like the body of a unified diff, with the line numbers on each side, e.g. "-2,0 b"
+func unified(lines []Line) []string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, fmt.Sprintf("%s%d,%d %s", l.Prefix(), l.OldNumber, l.NewNumber, l.Text))
+	}
+	return out
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{"Both empty", "", "", nil},
+		{"Unchanged", "a\nb\n", "a\nb\n", []string{" 1,1 a", " 2,2 b"}},
+		{"Everything inserted", "", "a\nb", []string{"+0,1 a", "+0,2 b"}},
+		{"Everything deleted", "a\nb", "", []string{"-1,0 a", "-2,0 b"}},
+		{"Line changed", "a\nb\nc", "a\nB\nc", []string{" 1,1 a", "-2,0 b", "+0,2 B", " 3,3 c"}},
+		{"Line inserted", "a\nc", "a\nb\nc", []string{" 1,1 a", "+0,2 b", " 2,3 c"}},
+		{"Line deleted", "a\nb\nc", "a\nc", []string{" 1,1 a", "-2,0 b", " 3,2 c"}},
+		{"Trailing newline ignored", "a\nb", "a\nb\n", []string{" 1,1 a", " 2,2 b"}},
+		{"Windows line endings", "a\r\nb\r\n", "a\nb\n", []string{" 1,1 a", " 2,2 b"}},
+		{"Lines moved", "a\nb\nc", "c\na\nb", []string{"+0,1 c", " 1,2 a", " 2,3 b", "-3,0 c"}},
+		{"Blank lines", "a\n\nb", "a\nb", []string{" 1,1 a", "-2,0 ", " 3,2 b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unified(Lines(tt.old, tt.new))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b, the slow and obviously correct way. a shortest edit script keeps exactly those lines, and deletes or inserts all the others
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// a small alphabet makes for plenty of repeated lines, which is where the edit scripts get interesting
+	randomText := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := range 2000 {
+		a, b := randomText(), randomText()
+		lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		// the diff must rebuild both texts, with the lines numbered in order on each side
+		var gotOld, gotNew []string
+		edits := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				gotOld = append(gotOld, l.Text)
+				if l.OldNumber != len(gotOld) {
+					t.Fatalf("case %d: got old line number %d; want %d", i, l.OldNumber, len(gotOld))
+				}
+			}
+			if l.Op != Delete {
+				gotNew = append(gotNew, l.Text)
+				if l.NewNumber != len(gotNew) {
+					t.Fatalf("case %d: got new line number %d; want %d", i, l.NewNumber, len(gotNew))
+				}
+			}
+			if l.Op != Equal {
+				edits++
+			}
+		}
+		if !slices.Equal(gotOld, a) || !slices.Equal(gotNew, b) {
+			t.Fatalf("case %d: the diff from %q to %q doesn't rebuild them", i, a, b)
+		}
+
+		// and it must be as short as possible
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("case %d: got %d edits from %q to %q; want %d", i, edits, a, b, want)
+		}
+	}
+}
+
+func TestLinesTooManyEdits(t *testing.T) {
+	// with more than maxEdits changes, every old line is deleted and every new line inserted, rather than spending a long time on the shortest edit script. the shortest one would keep the "same" lines, which are between the changes so that they aren't skipped as an unchanged prefix or suffix
+	var a, b []string
+	for i := range maxEdits {
+		a = append(a, fmt.Sprintf("old %d", i), "same")
+		b = append(b, fmt.Sprintf("new %d", i), "same")
+	}
+	a = append(a, "old end")
+	b = append(b, "new end")
+
+	lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(lines) != len(a)+len(b) {
+		t.Fatalf("got %d lines; want %d", len(lines), len(a)+len(b))
+	}
+	for i, l := range lines {
+		want := Delete
+		if i >= len(a) {
+			want = Insert
+		}
+		if l.Op != want {
+			t.Fatalf("got line %d %s; want %s", i, l.Op, want)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	if Changed(Lines("a\nb", "a\nb")) {
+		t.Error("got changed for identical texts")
+	}
+	if !Changed(Lines("a\nb", "a\nc")) {
+		t.Error("got unchanged for different texts")
+	}
+}
+
+func TestHunks(t *testing.T) {
+	numbered := func(from, to int) string {
+		var lines []string
+		for i := from; i <= to; i++ {
+			lines = append(lines, fmt.Sprint(i))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	tests := []struct {
+		name        string
+		old         string
+		new         string
+		context     int
+		wantHeaders []string
+	}{
+		{"No changes", numbered(1, 10), numbered(1, 10), 3, nil},
+		{"Change in the middle", numbered(1, 20), strings.Replace(numbered(1, 20), "\n10\n", "\nten\n", 1), 3, []string{"@@ -7,7 +7,7 @@"}},
+		{"Change at the start", numbered(1, 10), "zero\n" + numbered(1, 10), 3, []string{"@@ -1,3 +1,4 @@"}},
+		{"Everything inserted", "", numbered(1, 2), 3, []string{"@@ -0,0 +1,2 @@"}},
+		{"Change at the end", numbered(1, 10), numbered(1, 9), 3, []string{"@@ -7,4 +7,3 @@"}},
+		{"Changes sharing context", numbered(1, 20), strings.NewReplacer("\n5\n", "\nfive\n", "\n10\n", "\nten\n").Replace(numbered(1, 20)), 3, []string{"@@ -2,12 +2,12 @@"}},
+		{"Changes far apart", numbered(1, 30), strings.NewReplacer("\n5\n", "\nfive\n", "\n25\n", "\ntwenty-five\n").Replace(numbered(1, 30)), 3, []string{"@@ -2,7 +2,7 @@", "@@ -22,7 +22,7 @@"}},
+		{"No context", numbered(1, 10), strings.Replace(numbered(1, 10), "\n5\n", "\nfive\n", 1), 0, []string{"@@ -5,1 +5,1 @@"}},
+		{"Everything deleted", numbered(1, 3), "", 3, []string{"@@ -1,3 +0,0 @@"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			for _, h := range Hunks(Lines(tt.old, tt.new), tt.context) {
+				headers = append(headers, h.Header())
+			}
+			if !slices.Equal(headers, tt.wantHeaders) {
+				t.Errorf("got %q; want %q", headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	// side by side, show each side's line as its prefix and text, or _ when that side is empty
+	side := func(l *Line) string {
+		if l == nil {
+			return "_"
+		}
+		return l.Prefix() + l.Text
+	}
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{"Changed line", "a\nb\nc", "a\nB\nc", []string{" a| a", "-b|+B", " c| c"}},
+		{"More deleted than inserted", "a\nb\nc\nd", "a\nB\nd", []string{" a| a", "-b|+B", "-c|_", " d| d"}},
+		{"More inserted than deleted", "a\nb\nd", "a\nB\nC\nd", []string{" a| a", "-b|+B", "_|+C", " d| d"}},
+		{"Only inserted", "a\nc", "a\nb\nc", []string{" a| a", "_|+b", " c| c"}},
+		{"Only deleted", "a\nb\nc", "a\nc", []string{" a| a", "-b|_", " c| c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := Hunks(Lines(tt.old, tt.new), 3)
+			if len(hunks) != 1 {
+				t.Fatalf("got %d hunks; want 1", len(hunks))
+			}
+
+			var got []string
+			for _, row := range hunks[0].Rows() {
+				got = append(got, side(row.Left)+"|"+side(row.Right))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Package migrations holds the versioned database schema for every supported dialect, embedded into the binary, along with a Migrator which applies and rolls back the migrations and records which versions have been applied in the schema_migrations table
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// embed the .sql files for all the dialects. each file is named <version>_<name>.up.sql or <version>_<name>.down.sql, and lives in a directory named after its dialect
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is a single versioned change to the schema, with the SQL to apply it and to roll it back
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database, and when
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load returns the embedded migrations for a dialect, ordered by version
+func Load(dialect models.Dialect) ([]Migration, error) {
+	dir := string(dialect)
+	if dir == "" {
+		dir = string(models.MySQL)
+	}
+
+	paths, err := fs.Glob(files, dir+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, p := range paths {
+		// split a filename like 0001_create_users.up.sql into its version, name and direction
+		base := strings.TrimSuffix(path.Base(p), ".sql")
+		stem, direction := base[:strings.LastIndex(base, ".")], base[strings.LastIndex(base, ".")+1:]
+		versionText, name, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: malformed filename %s", p)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: malformed version in %s", p)
+		}
+
+		contents, err := files.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		switch direction {
+		case "up":
+			m.Up = string(contents)
+		case "down":
+			m.Down = string(contents)
+		default:
+			return nil, fmt.Errorf("migrations: %s must end in .up.sql or .down.sql", p)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations for its dialect to a database
+type Migrator struct {
+	DB      *sql.DB
+	Dialect models.Dialect
+}
+
+// init makes sure the schema_migrations table, which records the applied versions, exists
+func (m *Migrator) init() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied TIMESTAMP NOT NULL
+	)`
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+// Status returns every known migration along with whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Load(m.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		at, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: at}
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations which haven't been applied yet, in the order they should be applied
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in order, and returns the ones it applied. it stops at the first failure
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range pending {
+		stmt := m.Dialect.Rebind(`INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`)
+		err := m.run(migration.Up, stmt, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return applied, fmt.Errorf("migrations: applying %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migration. it returns nil if there was nothing to roll back
+func (m *Migrator) Down() (*Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		stmt := m.Dialect.Rebind(`DELETE FROM schema_migrations WHERE version = ?`)
+		err := m.run(migration.Down, stmt, migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: rolling back %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+
+	return nil, nil
+}
+
+// run executes the statements in a migration script followed by the bookkeeping statement in a single transaction. postgres and sqlite roll back schema changes along with the transaction, but note that mysql commits each schema change implicitly
+func (m *Migrator) run(script string, bookkeeping string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range split(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// split breaks a migration script into individual statements, because the mysql driver won't run more than one statement per Exec() call. statements end with a semicolon at the end of a line, except inside a BEGIN ... END block such as a trigger body
+func split(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inBlock := false
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		upper := strings.ToUpper(trimmed)
+		switch {
+		case strings.HasSuffix(upper, "BEGIN"):
+			inBlock = true
+		case inBlock && (upper == "END;" || upper == "END"):
+			inBlock = false
+			fallthrough
+		case !inBlock && strings.HasSuffix(trimmed, ";"):
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
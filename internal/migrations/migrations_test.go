@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "Empty",
+			script: "",
+			want:   nil,
+		},
+		{
+			name:   "Statements",
+			script: "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\n",
+			want:   []string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE b (id INTEGER);"},
+		},
+		{
+			name:   "Statement over several lines",
+			script: "CREATE TABLE a (\n    id INTEGER\n);\n",
+			want:   []string{"CREATE TABLE a (\n    id INTEGER\n);"},
+		},
+		{
+			name:   "Comments and blank lines",
+			script: "-- the first table\n\nCREATE TABLE a (\n    -- the key\n    id INTEGER\n);\n\n  -- indented comment\n",
+			want:   []string{"CREATE TABLE a (\n    id INTEGER\n);"},
+		},
+		{
+			name:   "Final statement without a semicolon",
+			script: "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER)\n",
+			want:   []string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE b (id INTEGER)"},
+		},
+		{
+			// the semicolons inside a trigger body don't end the statement, only the END; after them does
+			name: "SQLite trigger",
+			script: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n" +
+				"    INSERT INTO b (id) VALUES (new.id);\n" +
+				"    INSERT INTO c (id) VALUES (new.id);\n" +
+				"END;\n" +
+				"CREATE TABLE d (id INTEGER);\n",
+			want: []string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    INSERT INTO b (id) VALUES (new.id);\n    INSERT INTO c (id) VALUES (new.id);\nEND;",
+				"CREATE TABLE d (id INTEGER);",
+			},
+		},
+		{
+			name:   "Lowercase trigger",
+			script: "create trigger t after delete on a begin\n    delete from b where id = old.id;\nend;\n",
+			want:   []string{"create trigger t after delete on a begin\n    delete from b where id = old.id;\nend;"},
+		},
+		{
+			name:   "Trigger without a final semicolon",
+			script: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    INSERT INTO b (id) VALUES (new.id);\nEND\n",
+			want:   []string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    INSERT INTO b (id) VALUES (new.id);\nEND"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := split(tt.script); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect models.Dialect
+	}{
+		{"MySQL", models.MySQL},
+		{"Postgres", models.Postgres},
+		{"SQLite", models.SQLite},
+		{"Default", ""},
+	}
+
+	// every dialect should have the same migrations, so the schema version means the same thing whichever database is used
+	mysql, err := Load(models.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(migrations) == 0 {
+				t.Fatal("got no migrations")
+			}
+
+			for i, m := range migrations {
+				if m.Version != i+1 {
+					t.Errorf("got version %d at position %d; want %d", m.Version, i, i+1)
+				}
+				if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+					t.Errorf("got migration %d %s without both an up and a down script", m.Version, m.Name)
+				}
+				if len(split(m.Up)) == 0 || len(split(m.Down)) == 0 {
+					t.Errorf("got migration %d %s with no statements in one of its scripts", m.Version, m.Name)
+				}
+			}
+
+			if len(migrations) != len(mysql) {
+				t.Fatalf("got %d migrations; want %d, like MySQL", len(migrations), len(mysql))
+			}
+			for i := range migrations {
+				if migrations[i].Version != mysql[i].Version || migrations[i].Name != mysql[i].Name {
+					t.Errorf("got migration %d %s; want %d %s, like MySQL", migrations[i].Version, migrations[i].Name, mysql[i].Version, mysql[i].Name)
+				}
+			}
+		})
+	}
+}
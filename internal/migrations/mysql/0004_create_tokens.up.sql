@@ -0,0 +1,10 @@
+CREATE TABLE tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    hash BINARY(32) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash),
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
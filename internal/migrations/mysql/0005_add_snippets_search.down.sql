@@ -0,0 +1 @@
+DROP INDEX snippets_ft ON snippets;
@@ -0,0 +1 @@
+CREATE FULLTEXT INDEX snippets_ft ON snippets (title, content);
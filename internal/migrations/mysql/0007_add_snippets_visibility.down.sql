@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_visibility_created ON snippets;
+
+ALTER TABLE snippets DROP COLUMN visibility;
@@ -0,0 +1,3 @@
+DROP INDEX snippets_uc_slug ON snippets;
+
+ALTER TABLE snippets DROP COLUMN slug;
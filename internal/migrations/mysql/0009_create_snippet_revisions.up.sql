@@ -0,0 +1,16 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision),
+    CONSTRAINT snippet_revisions_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE,
+    CONSTRAINT snippet_revisions_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- the current state of every existing snippet becomes its first revision
+INSERT INTO snippet_revisions (snippet_id, revision, title, content, user_id, created)
+SELECT id, 1, title, content, user_id, created FROM snippets;
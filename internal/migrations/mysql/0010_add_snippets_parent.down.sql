@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY snippets_fk_parent;
+
+ALTER TABLE snippets DROP COLUMN parent_id;
@@ -0,0 +1,4 @@
+-- parent_id records the snippet a fork was made from. it is set to NULL if the parent is deleted, so forks outlive their parents
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_parent FOREIGN KEY (parent_id) REFERENCES snippets (id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP TABLE snippet_revision_files;
+
+DROP TABLE snippet_files;
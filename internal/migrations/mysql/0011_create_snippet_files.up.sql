@@ -0,0 +1,30 @@
+-- snippet_files holds the files of each snippet in order. the content and language of the first file are also kept in the snippets table, so that listing and searching snippets doesn't need a join
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    language VARCHAR(50) NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position),
+    CONSTRAINT snippet_files_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
+
+-- snippet_revision_files holds the files as they were at each revision
+CREATE TABLE snippet_revision_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    revision_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    language VARCHAR(50) NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position),
+    CONSTRAINT snippet_revision_files_fk_revision FOREIGN KEY (revision_id) REFERENCES snippet_revisions (id) ON DELETE CASCADE
+);
+
+-- every existing snippet becomes a single unnamed file, and so do its revisions. revisions didnt record the language, so they are given the snippet's current one
+INSERT INTO snippet_files (snippet_id, position, filename, language, content)
+SELECT id, 1, '', language, content FROM snippets;
+
+INSERT INTO snippet_revision_files (revision_id, position, filename, language, content)
+SELECT r.id, 1, '', s.language, r.content FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id;
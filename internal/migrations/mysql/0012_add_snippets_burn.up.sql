@@ -0,0 +1,2 @@
+-- snippets with burn_after_reading set are deleted the first time someone other than their owner views them
+ALTER TABLE snippets ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+DROP INDEX snippets_ft ON snippets;
+
+CREATE FULLTEXT INDEX snippets_ft ON snippets (title, content);
+
+ALTER TABLE snippets DROP COLUMN search_content;
@@ -0,0 +1,17 @@
+-- search_content holds the content of every file in a snippet, one after the other, so that the full-text index can search all of them. the content column only has the first file
+ALTER TABLE snippets ADD COLUMN search_content MEDIUMTEXT;
+
+-- GROUP_CONCAT() cuts its result off at 1024 bytes by default, which is a lot less than the files of one snippet can add up to
+SET SESSION group_concat_max_len = 16777215;
+
+UPDATE snippets s SET search_content = (
+    SELECT GROUP_CONCAT(f.content ORDER BY f.position SEPARATOR '\n') FROM snippet_files f WHERE f.snippet_id = s.id
+);
+
+UPDATE snippets SET search_content = content WHERE search_content IS NULL;
+
+ALTER TABLE snippets MODIFY COLUMN search_content MEDIUMTEXT NOT NULL;
+
+DROP INDEX snippets_ft ON snippets;
+
+CREATE FULLTEXT INDEX snippets_ft ON snippets (title, search_content);
@@ -0,0 +1,9 @@
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL,
+    created TIMESTAMP NOT NULL,
+    last_used TIMESTAMP NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
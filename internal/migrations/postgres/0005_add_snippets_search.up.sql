@@ -0,0 +1 @@
+CREATE INDEX snippets_ft ON snippets USING GIN (to_tsvector('english', title || ' ' || content));
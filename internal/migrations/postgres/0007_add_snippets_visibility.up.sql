@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+
+CREATE INDEX idx_snippets_visibility_created ON snippets (visibility, created);
@@ -0,0 +1,8 @@
+-- existing snippets get a random slug of 10 hex characters. new snippets get a base62 slug generated by the application
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NULL;
+
+UPDATE snippets SET slug = SUBSTRING(MD5(RANDOM()::TEXT || id::TEXT), 1, 10);
+
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets (slug);
@@ -0,0 +1,5 @@
+DROP INDEX snippets_ft;
+
+CREATE INDEX snippets_ft ON snippets USING GIN (to_tsvector('english', title || ' ' || content));
+
+ALTER TABLE snippets DROP COLUMN search_content;
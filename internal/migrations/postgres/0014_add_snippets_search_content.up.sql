@@ -0,0 +1,11 @@
+-- search_content holds the content of every file in a snippet, one after the other, so that the full-text index can search all of them. the content column only has the first file
+ALTER TABLE snippets ADD COLUMN search_content TEXT NOT NULL DEFAULT '';
+
+UPDATE snippets s SET search_content = COALESCE(
+    (SELECT string_agg(f.content, E'\n' ORDER BY f.position) FROM snippet_files f WHERE f.snippet_id = s.id),
+    s.content
+);
+
+DROP INDEX snippets_ft;
+
+CREATE INDEX snippets_ft ON snippets USING GIN (to_tsvector('english', title || ' ' || search_content));
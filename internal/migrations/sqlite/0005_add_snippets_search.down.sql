@@ -0,0 +1,7 @@
+DROP TRIGGER snippets_ft_update;
+
+DROP TRIGGER snippets_ft_delete;
+
+DROP TRIGGER snippets_ft_insert;
+
+DROP TABLE snippets_ft;
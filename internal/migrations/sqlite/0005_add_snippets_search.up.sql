@@ -0,0 +1,17 @@
+-- an external content FTS5 table indexes the title and content of snippets, and the triggers keep it in sync
+CREATE VIRTUAL TABLE snippets_ft USING fts5 (title, content, content = 'snippets', content_rowid = 'id');
+
+INSERT INTO snippets_ft (rowid, title, content) SELECT id, title, content FROM snippets;
+
+CREATE TRIGGER snippets_ft_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_ft (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_ft_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_ft (snippets_ft, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+
+CREATE TRIGGER snippets_ft_update AFTER UPDATE ON snippets BEGIN
+    INSERT INTO snippets_ft (snippets_ft, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_ft (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
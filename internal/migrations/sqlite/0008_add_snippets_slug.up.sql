@@ -0,0 +1,6 @@
+-- existing snippets get a random slug of 10 hex characters. new snippets get a base62 slug generated by the application. sqlite can't add a NOT NULL constraint to an existing column, so here the column stays nullable
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NULL;
+
+UPDATE snippets SET slug = LOWER(HEX(RANDOMBLOB(5)));
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets (slug);
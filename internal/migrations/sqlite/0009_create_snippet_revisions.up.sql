@@ -0,0 +1,14 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+-- the current state of every existing snippet becomes its first revision
+INSERT INTO snippet_revisions (snippet_id, revision, title, content, user_id, created)
+SELECT id, 1, title, content, user_id, created FROM snippets;
@@ -0,0 +1,5 @@
+DROP TRIGGER snippets_parent_delete;
+
+DROP INDEX idx_snippets_parent;
+
+ALTER TABLE snippets DROP COLUMN parent_id;
@@ -0,0 +1,8 @@
+-- parent_id records the snippet a fork was made from. sqlite won't drop a column which is part of a foreign key, which would make this migration impossible to roll back, so instead of ON DELETE SET NULL a trigger clears parent_id when the parent is deleted
+ALTER TABLE snippets ADD COLUMN parent_id INTEGER NULL;
+
+CREATE INDEX idx_snippets_parent ON snippets (parent_id);
+
+CREATE TRIGGER snippets_parent_delete AFTER DELETE ON snippets BEGIN
+    UPDATE snippets SET parent_id = NULL WHERE parent_id = old.id;
+END;
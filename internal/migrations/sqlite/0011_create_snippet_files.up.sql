@@ -0,0 +1,28 @@
+-- snippet_files holds the files of each snippet in order. the content and language of the first file are also kept in the snippets table, so that listing and searching snippets doesn't need a join
+CREATE TABLE snippet_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    language TEXT NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position)
+);
+
+-- snippet_revision_files holds the files as they were at each revision
+CREATE TABLE snippet_revision_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    revision_id INTEGER NOT NULL REFERENCES snippet_revisions (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    language TEXT NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position)
+);
+
+-- every existing snippet becomes a single unnamed file, and so do its revisions. revisions didnt record the language, so they are given the snippet's current one
+INSERT INTO snippet_files (snippet_id, position, filename, language, content)
+SELECT id, 1, '', language, content FROM snippets;
+
+INSERT INTO snippet_revision_files (revision_id, position, filename, language, content)
+SELECT r.id, 1, '', s.language, r.content FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id;
@@ -0,0 +1,33 @@
+-- search_content holds the content of every file in a snippet, one after the other, so that the full-text index can search all of them. the content column only has the first file
+ALTER TABLE snippets ADD COLUMN search_content TEXT NOT NULL DEFAULT '';
+
+-- the FTS5 table is rebuilt on the new column. it goes first, so that its update trigger doesn't fire for the backfill below
+DROP TRIGGER snippets_ft_update;
+
+DROP TRIGGER snippets_ft_delete;
+
+DROP TRIGGER snippets_ft_insert;
+
+DROP TABLE snippets_ft;
+
+UPDATE snippets SET search_content = COALESCE(
+    (SELECT group_concat(f.content, char(10) ORDER BY f.position) FROM snippet_files f WHERE f.snippet_id = snippets.id),
+    content
+);
+
+CREATE VIRTUAL TABLE snippets_ft USING fts5 (title, search_content, content = 'snippets', content_rowid = 'id');
+
+INSERT INTO snippets_ft (rowid, title, search_content) SELECT id, title, search_content FROM snippets;
+
+CREATE TRIGGER snippets_ft_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_ft (rowid, title, search_content) VALUES (new.id, new.title, new.search_content);
+END;
+
+CREATE TRIGGER snippets_ft_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_ft (snippets_ft, rowid, title, search_content) VALUES ('delete', old.id, old.title, old.search_content);
+END;
+
+CREATE TRIGGER snippets_ft_update AFTER UPDATE ON snippets BEGIN
+    INSERT INTO snippets_ft (snippets_ft, rowid, title, search_content) VALUES ('delete', old.id, old.title, old.search_content);
+    INSERT INTO snippets_ft (rowid, title, search_content) VALUES (new.id, new.title, new.search_content);
+END;
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect identifies the flavour of SQL spoken by the database behind a model. the zero value behaves like MySQL, so models created without a dialect keep working as before
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// ParseDialect converts a driver name from the command line into a Dialect, returning an error for unsupported names
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(strings.ToLower(name)); d {
+	case MySQL, Postgres, SQLite:
+		return d, nil
+	default:
+		return "", fmt.Errorf("models: unsupported database driver %q (must be mysql, postgres or sqlite)", name)
+	}
+}
+
+// DriverName returns the name that the dialect's database/sql driver is registered under, for use with sql.Open()
+func (d Dialect) DriverName() string {
+	switch d {
+	case Postgres:
+		return "pgx"
+	case SQLite:
+		return "sqlite"
+	default:
+		return "mysql"
+	}
+}
+
+// Rebind rewrites the ? placeholders in a query into the $1, $2... form that postgres expects. all the queries in this package are written with ? placeholders, and must not contain a literal ? anywhere else
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forUpdate returns the clause which makes a SELECT lock the rows it reads until the end of the transaction, or nothing for sqlite. sqlite doesnt support row locks, but it only lets one transaction write at a time anyway
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// querier is the part of the interface shared by *sql.DB and *sql.Tx which the helpers in this package need, so that they work both inside and outside a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// insert executes an INSERT statement and returns the id of the new row. postgres doesnt support LastInsertId(), so for it we append a RETURNING clause and read the id back instead
+func (d Dialect) insert(db querier, query string, args ...any) (int, error) {
+	if d == Postgres {
+		var id int
+		err := db.QueryRow(d.Rebind(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// isUniqueViolation reports whether err was caused by a unique constraint. mysql and postgres report the name of the violated constraint, while sqlite only reports the table and column in its message, so the caller passes both
+func (d Dialect) isUniqueViolation(err error, constraint, column string) bool {
+	switch d {
+	case Postgres:
+		var pgError *pgconn.PgError
+		if errors.As(err, &pgError) {
+			return pgError.Code == "23505" && pgError.ConstraintName == constraint
+		}
+	case SQLite:
+		var sqliteError *sqlite.Error
+		if errors.As(err, &sqliteError) {
+			return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), column)
+		}
+	default:
+		// mysql reports a duplicate entry with error number 1062, and includes the name of the key in the message
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+		}
+	}
+	return false
+}
@@ -8,4 +8,6 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email address")
+
+	ErrNoFiles = errors.New("models: snippet has no files")
 )
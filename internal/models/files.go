@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// File is one of the files in a snippet. every snippet has at least one file, and the Content and Language of a Snippet are always those of its first file. Filename can be blank, in which case the file is shown without a name
+type File struct {
+	Filename string `json:"filename"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// MaxFiles is the most files a single snippet can hold
+const MaxFiles = 20
+
+// filesEqual reports whether two lists of files have the same names, languages and contents in the same order
+func filesEqual(a, b []File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// searchContent joins the contents of all the files, in order, for the search_content column of the snippets table which the full-text search indexes
+func searchContent(files []File) string {
+	contents := make([]string, len(files))
+	for i, f := range files {
+		contents[i] = f.Content
+	}
+	return strings.Join(contents, "\n")
+}
+
+// insertFiles stores the files in table, which is either snippet_files or snippet_revision_files, against the id of their snippet or revision. positions count up from 1
+func (m *SnippetModel) insertFiles(tx *sql.Tx, table string, column string, id int, files []File) error {
+	stmt := `INSERT INTO ` + table + ` (` + column + `, position, filename, language, content) VALUES (?, ?, ?, ?, ?)`
+
+	for i, f := range files {
+		_, err := tx.Exec(m.Dialect.Rebind(stmt), id, i+1, f.Filename, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryFiles returns the files in table which belong to the snippet or revision with the given id, in order
+func (m *SnippetModel) queryFiles(db querier, table string, column string, id int) ([]File, error) {
+	stmt := `SELECT filename, language, content FROM ` + table + ` WHERE ` + column + ` = ? ORDER BY position`
+
+	rows, err := db.Query(m.Dialect.Rebind(stmt), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		var f File
+		err := rows.Scan(&f.Filename, &f.Language, &f.Content)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
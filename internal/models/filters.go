@@ -0,0 +1,74 @@
+package models
+
+import (
+	"math"
+	"strings"
+)
+
+// SortSafelist holds the values accepted for Filters.Sort. a leading - sorts in descending order
+var SortSafelist = []string{"created", "-created", "expires", "-expires", "title", "-title"}
+
+// Filters controls which page of a list of snippets is returned, how big the pages are, and how the list is sorted
+type Filters struct {
+	Page     int
+	PageSize int
+	Sort     string
+}
+
+// orderBy returns the ORDER BY clause for the sort. the sort must already have been checked against SortSafelist, because it is interpolated into the SQL. the id is used as a tie breaker so that pages are stable
+func (f Filters) orderBy() string {
+	column, direction := strings.TrimPrefix(f.Sort, "-"), "ASC"
+	if strings.HasPrefix(f.Sort, "-") {
+		direction = "DESC"
+	}
+
+	switch column {
+	case "created", "expires", "title":
+	default:
+		column, direction = "created", "DESC"
+	}
+
+	return "s." + column + " " + direction + ", s.id " + direction
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// Metadata describes where a page sits in the full list of results, so that templates and API clients can link to the other pages
+type Metadata struct {
+	CurrentPage  int `json:"current_page"`
+	PageSize     int `json:"page_size"`
+	FirstPage    int `json:"first_page"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+// CalculateMetadata works out the pagination metadata from the total number of records. if there are no records it returns an empty Metadata
+func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
+
+// HasPrevious reports whether there is a page before the current one
+func (m Metadata) HasPrevious() bool {
+	return m.CurrentPage > m.FirstPage
+}
+
+// HasNext reports whether there is a page after the current one
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
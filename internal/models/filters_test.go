@@ -0,0 +1,76 @@
+package models
+
+import "testing"
+
+func TestFiltersOrderBy(t *testing.T) {
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"created", "s.created ASC, s.id ASC"},
+		{"-created", "s.created DESC, s.id DESC"},
+		{"expires", "s.expires ASC, s.id ASC"},
+		{"-title", "s.title DESC, s.id DESC"},
+		// anything which isn't in the safelist falls back to newest first, so that it can never be interpolated into the SQL
+		{"id; DROP TABLE snippets", "s.created DESC, s.id DESC"},
+		{"", "s.created DESC, s.id DESC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			if got := (Filters{Sort: tt.sort}).orderBy(); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFiltersOffset(t *testing.T) {
+	tests := []struct {
+		page     int
+		pageSize int
+		want     int
+	}{
+		{1, 10, 0},
+		{2, 10, 10},
+		{3, 25, 50},
+		{10_000_000, 100, 999_999_900},
+	}
+
+	for _, tt := range tests {
+		if got := (Filters{Page: tt.page, PageSize: tt.pageSize}).offset(); got != tt.want {
+			t.Errorf("got offset %d for page %d of %d; want %d", got, tt.page, tt.pageSize, tt.want)
+		}
+	}
+}
+
+func TestCalculateMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		totalRecords int
+		page         int
+		pageSize     int
+		want         Metadata
+		wantPrevious bool
+		wantNext     bool
+	}{
+		{"No records", 0, 1, 10, Metadata{}, false, false},
+		{"One page", 5, 1, 10, Metadata{CurrentPage: 1, PageSize: 10, FirstPage: 1, LastPage: 1, TotalRecords: 5}, false, false},
+		{"Exactly full pages", 20, 1, 10, Metadata{CurrentPage: 1, PageSize: 10, FirstPage: 1, LastPage: 2, TotalRecords: 20}, false, true},
+		{"Middle page", 21, 2, 10, Metadata{CurrentPage: 2, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 21}, true, true},
+		{"Last page", 21, 3, 10, Metadata{CurrentPage: 3, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 21}, true, false},
+		{"Past the last page", 21, 5, 10, Metadata{CurrentPage: 5, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 21}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateMetadata(tt.totalRecords, tt.page, tt.pageSize)
+			if got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+			if got.HasPrevious() != tt.wantPrevious || got.HasNext() != tt.wantNext {
+				t.Errorf("got previous %t and next %t; want %t and %t", got.HasPrevious(), got.HasNext(), tt.wantPrevious, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// SnippetModel is an in-memory implementation of models.SnippetModelInterface. it needs the UserModel so that it can fill in the author's name on each snippet, like the sql join does
+type SnippetModel struct {
+	mu        sync.RWMutex
+	snippets  map[int]*models.Snippet
+	revisions map[int][]*models.Revision // the revisions of each snippet, oldest first
+	nextID    int
+	users     *UserModel
+}
+
+func NewSnippetModel(users *UserModel) *SnippetModel {
+	return &SnippetModel{
+		snippets:  make(map[int]*models.Snippet),
+		revisions: make(map[int][]*models.Revision),
+		nextID:    1,
+		users:     users,
+	}
+}
+
+func (m *SnippetModel) Insert(title string, files []models.File, visibility string, expires time.Duration, burnAfterReading bool, userID int, parentID int) (int, string, error) {
+	if len(files) == 0 {
+		return 0, "", models.ErrNoFiles
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, "", err
+	}
+	for m.slugTaken(slug) {
+		slug, err = models.NewSlug()
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	now := time.Now().UTC()
+	id := m.nextID
+	m.snippets[id] = &models.Snippet{
+		ID:               id,
+		Slug:             slug,
+		Title:            title,
+		Content:          files[0].Content,
+		Language:         files[0].Language,
+		Visibility:       visibility,
+		Created:          now,
+		Expires:          expiresAt(now, expires),
+		UserID:           userID,
+		ParentID:         parentID,
+		Files:            append([]models.File(nil), files...),
+		BurnAfterReading: burnAfterReading,
+	}
+	m.nextID++
+	m.addRevision(m.snippets[id], now)
+
+	return id, slug, nil
+}
+
+// addRevision records the snippet's current title and files as its next revision. the caller must hold the lock
+func (m *SnippetModel) addRevision(s *models.Snippet, created time.Time) {
+	m.revisions[s.ID] = append(m.revisions[s.ID], &models.Revision{
+		SnippetID: s.ID,
+		Number:    len(m.revisions[s.ID]) + 1,
+		Title:     s.Title,
+		Content:   s.Content,
+		UserID:    s.UserID,
+		Created:   created,
+		Files:     s.Files,
+	})
+}
+
+// slugTaken reports whether any snippet already has the slug. the caller must hold the lock
+func (m *SnippetModel) slugTaken(slug string) bool {
+	for _, s := range m.snippets {
+		if s.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(time.Now()) {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.copy(s), nil
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, s := range m.snippets {
+		if s.Slug == slug && s.Expires.After(time.Now()) {
+			return m.copy(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Update(id int, title string, files []models.File, visibility string, expires time.Duration, burnAfterReading bool) error {
+	if len(files) == 0 {
+		return models.ErrNoFiles
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	changed := s.Title != title || !slices.Equal(s.Files, files)
+
+	s.Title = title
+	s.Content = files[0].Content
+	s.Language = files[0].Language
+	s.Visibility = visibility
+	s.BurnAfterReading = burnAfterReading
+	if expires != 0 {
+		s.Expires = expiresAt(time.Now().UTC(), expires)
+	}
+
+	if changed {
+		// the stored files are never modified in place, so the revision can share the new slice with the snippet
+		s.Files = append([]models.File(nil), files...)
+		m.addRevision(s, time.Now().UTC())
+	}
+
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+	m.remove(id)
+
+	return nil
+}
+
+// remove deletes a snippet along with its revisions. the caller must hold the lock
+func (m *SnippetModel) remove(id int) {
+	delete(m.snippets, id)
+	delete(m.revisions, id)
+
+	// like ON DELETE SET NULL, forks outlive their parent
+	for _, s := range m.snippets {
+		if s.ParentID == id {
+			s.ParentID = 0
+		}
+	}
+}
+
+// Burn deletes the snippet if it burns after reading. the lock makes sure that only one caller can succeed
+func (m *SnippetModel) Burn(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.BurnAfterReading {
+		return models.ErrNoRecord
+	}
+	m.remove(id)
+
+	return nil
+}
+
+// DeleteExpired removes up to limit expired snippets, the ones which expired first going first
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var expired []*models.Snippet
+	for _, s := range m.snippets {
+		if !s.Expires.After(now) {
+			expired = append(expired, s)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Expires.Before(expired[j].Expires) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		m.remove(s.ID)
+	}
+
+	return len(expired), nil
+}
+
+// expiresAt works out the expiry time like the sql model does, with models.Never meaning models.Forever
+func expiresAt(now time.Time, d time.Duration) time.Time {
+	if d == models.Never || now.Add(d).After(models.Forever) {
+		return models.Forever
+	}
+	return now.Add(d)
+}
+
+func (m *SnippetModel) Latest(filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	snippets := m.filter(func(s *models.Snippet) bool { return s.Visibility == models.VisibilityPublic })
+
+	// filter() returns the snippets newest first, which is also the order we fall back to for ties
+	descending := strings.HasPrefix(filters.Sort, "-")
+	less := func(a, b *models.Snippet) bool { return a.Created.Before(b.Created) }
+	switch strings.TrimPrefix(filters.Sort, "-") {
+	case "expires":
+		less = func(a, b *models.Snippet) bool { return a.Expires.Before(b.Expires) }
+	case "title":
+		less = func(a, b *models.Snippet) bool { return a.Title < b.Title }
+	}
+	sort.SliceStable(snippets, func(i, j int) bool {
+		if descending {
+			return less(snippets[j], snippets[i])
+		}
+		return less(snippets[i], snippets[j])
+	})
+
+	metadata := models.CalculateMetadata(len(snippets), filters.Page, filters.PageSize)
+
+	return paginate(snippets, filters.PageSize, (filters.Page-1)*filters.PageSize), metadata, nil
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	return m.filter(func(s *models.Snippet) bool { return s.UserID == userID }), nil
+}
+
+func (m *SnippetModel) Forks(parentID int) ([]*models.Snippet, error) {
+	return m.filter(func(s *models.Snippet) bool {
+		return s.ParentID == parentID && s.Visibility == models.VisibilityPublic
+	}), nil
+}
+
+// filter returns copies of the unexpired snippets for which keep returns true, newest first
+func (m *SnippetModel) filter(keep func(*models.Snippet) bool) []*models.Snippet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	snippets := []*models.Snippet{}
+	for _, s := range m.snippets {
+		if s.Expires.After(now) && keep(s) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].ID > snippets[j].ID })
+
+	return snippets
+}
+
+// copy returns a copy of s with the author's name filled in, so that callers can't modify the stored snippet
+func (m *SnippetModel) copy(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.UserName = m.users.name(s.UserID)
+	c.Files = slices.Clone(s.Files)
+	return &c
+}
+
+// paginate returns the window of snippets that a LIMIT and OFFSET clause would select. a negative offset, which the database would reject, selects nothing
+func paginate(snippets []*models.Snippet, limit, offset int) []*models.Snippet {
+	if offset < 0 || offset >= len(snippets) {
+		return []*models.Snippet{}
+	}
+	snippets = snippets[offset:]
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets
+}
+
+// Search ranks snippets by how many times the search terms appear in them, with matches in the title counting ten times as much as matches in the content. like the sqlite implementation, every term must appear somewhere in the snippet
+func (m *SnippetModel) Search(query string, page int) ([]*models.Snippet, bool, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return []*models.Snippet{}, false, nil
+	}
+
+	scores := map[int]int{}
+	snippets := m.filter(func(s *models.Snippet) bool {
+		if s.Visibility != models.VisibilityPublic {
+			return false
+		}
+
+		// the content of every file is searched, not just the first one in s.Content
+		contents := make([]string, len(s.Files))
+		for i, f := range s.Files {
+			contents[i] = f.Content
+		}
+		title, content := strings.ToLower(s.Title), strings.ToLower(strings.Join(contents, "\n"))
+		score := 0
+		for _, t := range terms {
+			n := 10*strings.Count(title, t) + strings.Count(content, t)
+			if n == 0 {
+				return false
+			}
+			score += n
+		}
+		scores[s.ID] = score
+		return true
+	})
+
+	// filter() returns the newest snippets first, so a stable sort keeps equally relevant snippets in that order
+	sort.SliceStable(snippets, func(i, j int) bool { return scores[snippets[i].ID] > scores[snippets[j].ID] })
+
+	if page < 1 {
+		page = 1
+	}
+	snippets = paginate(snippets, models.SearchPageSize+1, (page-1)*models.SearchPageSize)
+	if len(snippets) > models.SearchPageSize {
+		return snippets[:models.SearchPageSize], true, nil
+	}
+	return snippets, false, nil
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []*models.Revision{}
+	for i := len(m.revisions[snippetID]) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.copyRevision(m.revisions[snippetID][i]))
+	}
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(snippetID int, number int) (*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := m.revisions[snippetID]
+	if number < 1 || number > len(revisions) {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.copyRevision(revisions[number-1]), nil
+}
+
+// copyRevision returns a copy of r with the author's name filled in
+func (m *SnippetModel) copyRevision(r *models.Revision) *models.Revision {
+	c := *r
+	c.UserName = m.users.name(r.UserID)
+	c.Files = slices.Clone(r.Files)
+	return &c
+}
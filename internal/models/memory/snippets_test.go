@@ -0,0 +1,437 @@
+package memory
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// newTestSnippetModel returns an empty snippet model, along with the user model it looks names up in
+func newTestSnippetModel(t *testing.T) (*SnippetModel, *UserModel) {
+	t.Helper()
+
+	users := NewUserModel()
+	users.BcryptCost = 4 // bcrypt.MinCost, so that the tests don't spend their time hashing
+	if err := users.Insert("Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSnippetModel(users), users
+}
+
+// insert adds a snippet with a single file, failing the test if it can't
+func insert(t *testing.T, m *SnippetModel, title, content, visibility string, expires time.Duration) (int, string) {
+	t.Helper()
+
+	id, slug, err := m.Insert(title, []models.File{{Content: content}}, visibility, expires, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, slug
+}
+
+func TestSnippetModelInsertAndGet(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	files := []models.File{
+		{Filename: "main.go", Language: "go", Content: "package main"},
+		{Filename: "README.md", Language: "markdown", Content: "# Readme"},
+	}
+	id, slug, err := m.Insert("Two files", files, models.VisibilityUnlisted, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slug) != models.SlugLength {
+		t.Errorf("got slug %q; want %d characters", slug, models.SlugLength)
+	}
+
+	byID, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySlug, err := m.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []*models.Snippet{byID, bySlug} {
+		if s.ID != id || s.Slug != slug {
+			t.Errorf("got snippet %d %q; want %d %q", s.ID, s.Slug, id, slug)
+		}
+		// the snippet's content and language are always those of its first file
+		if s.Content != "package main" || s.Language != "go" {
+			t.Errorf("got content %q and language %q; want those of the first file", s.Content, s.Language)
+		}
+		if len(s.Files) != 2 {
+			t.Errorf("got %d files; want 2", len(s.Files))
+		}
+		if s.UserName != "Alice" {
+			t.Errorf("got user name %q; want %q", s.UserName, "Alice")
+		}
+	}
+
+	// changing the returned copy mustn't change the stored snippet
+	byID.Files[0].Content = "changed"
+	again, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Files[0].Content != "package main" {
+		t.Errorf("the stored snippet was changed through a copy")
+	}
+}
+
+func TestSnippetModelInsertNoFiles(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	_, _, err := m.Insert("Empty", nil, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if !errors.Is(err, models.ErrNoFiles) {
+		t.Errorf("got error %v; want %v", err, models.ErrNoFiles)
+	}
+}
+
+func TestSnippetModelGetMissing(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	expiredID, expiredSlug := insert(t, m, "Expired", "gone", models.VisibilityPublic, -time.Minute)
+
+	tests := []struct {
+		name string
+		get  func() (*models.Snippet, error)
+	}{
+		{"Unknown id", func() (*models.Snippet, error) { return m.Get(999) }},
+		{"Unknown slug", func() (*models.Snippet, error) { return m.GetBySlug("nosuchslug") }},
+		{"Expired id", func() (*models.Snippet, error) { return m.Get(expiredID) }},
+		{"Expired slug", func() (*models.Snippet, error) { return m.GetBySlug(expiredSlug) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.get()
+			if !errors.Is(err, models.ErrNoRecord) {
+				t.Errorf("got error %v; want %v", err, models.ErrNoRecord)
+			}
+		})
+	}
+}
+
+func TestSnippetModelNeverExpires(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	id, _ := insert(t, m, "Forever", "kept", models.VisibilityPublic, models.Never)
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.NeverExpires() {
+		t.Errorf("got expiry %s; want %s", s.Expires, models.Forever)
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	id, _ := insert(t, m, "First", "one", models.VisibilityPublic, time.Hour)
+
+	tests := []struct {
+		name          string
+		title         string
+		content       string
+		wantRevisions int
+	}{
+		{"Unchanged", "First", "one", 1},
+		{"New title", "Second", "one", 2},
+		{"New content", "Second", "two", 3},
+		{"Unchanged again", "Second", "two", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Update(id, tt.title, []models.File{{Content: tt.content}}, models.VisibilityPrivate, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := m.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Title != tt.title || s.Content != tt.content || s.Visibility != models.VisibilityPrivate {
+				t.Errorf("got %q %q %q; want %q %q %q", s.Title, s.Content, s.Visibility, tt.title, tt.content, models.VisibilityPrivate)
+			}
+
+			// a revision is only recorded when the title or files change
+			revisions, err := m.Revisions(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != tt.wantRevisions {
+				t.Errorf("got %d revisions; want %d", len(revisions), tt.wantRevisions)
+			}
+			if revisions[0].Number != tt.wantRevisions || revisions[0].Title != tt.title {
+				t.Errorf("got latest revision %d %q; want %d %q", revisions[0].Number, revisions[0].Title, tt.wantRevisions, tt.title)
+			}
+		})
+	}
+
+	if err := m.Update(999, "Missing", []models.File{{Content: "x"}}, models.VisibilityPublic, 0, false); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got error %v updating a missing snippet; want %v", err, models.ErrNoRecord)
+	}
+}
+
+func TestSnippetModelDelete(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	parentID, _ := insert(t, m, "Parent", "original", models.VisibilityPublic, time.Hour)
+	forkID, _, err := m.Insert("Fork", []models.File{{Content: "copy"}}, models.VisibilityPublic, time.Hour, false, 1, parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Delete(parentID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(parentID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got error %v getting a deleted snippet; want %v", err, models.ErrNoRecord)
+	}
+	if revisions, _ := m.Revisions(parentID); len(revisions) != 0 {
+		t.Errorf("got %d revisions of a deleted snippet; want 0", len(revisions))
+	}
+
+	// like ON DELETE SET NULL, the fork outlives its parent
+	fork, err := m.Get(forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ParentID != 0 {
+		t.Errorf("got parent id %d; want 0", fork.ParentID)
+	}
+
+	if err := m.Delete(parentID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got error %v deleting twice; want %v", err, models.ErrNoRecord)
+	}
+}
+
+func TestSnippetModelBurn(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	keptID, _ := insert(t, m, "Kept", "kept", models.VisibilityPublic, time.Hour)
+	burnID, _, err := m.Insert("Burn", []models.File{{Content: "secret"}}, models.VisibilityUnlisted, time.Hour, true, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		id      int
+		wantErr error
+	}{
+		{"Burns after reading", burnID, nil},
+		{"Only burns once", burnID, models.ErrNoRecord},
+		{"Doesn't burn after reading", keptID, models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Burn(tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := m.Get(keptID); err != nil {
+		t.Errorf("got error %v getting the snippet which doesn't burn", err)
+	}
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	insert(t, m, "Live", "live", models.VisibilityPublic, time.Hour)
+	oldest, _ := insert(t, m, "Oldest", "old", models.VisibilityPublic, -3*time.Hour)
+	insert(t, m, "Older", "old", models.VisibilityPublic, -2*time.Hour)
+	insert(t, m, "Old", "old", models.VisibilityPublic, -time.Hour)
+
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"First batch", 2, 2},
+		{"Short batch", 2, 1},
+		{"Nothing left", 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := m.DeleteExpired(tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("got %d deleted; want %d", n, tt.want)
+			}
+		})
+	}
+
+	// the snippets which expired first go first
+	if _, ok := m.snippets[oldest]; ok {
+		t.Errorf("the snippet which expired first wasn't deleted")
+	}
+	if len(m.snippets) != 1 {
+		t.Errorf("got %d snippets left; want 1", len(m.snippets))
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	for _, title := range []string{"Banana", "Apple", "Cherry", "Date", "Elderberry"} {
+		insert(t, m, title, "fruit", models.VisibilityPublic, time.Hour)
+	}
+	insert(t, m, "Private", "hidden", models.VisibilityPrivate, time.Hour)
+	insert(t, m, "Unlisted", "hidden", models.VisibilityUnlisted, time.Hour)
+	insert(t, m, "Expired", "gone", models.VisibilityPublic, -time.Hour)
+
+	tests := []struct {
+		name         string
+		filters      models.Filters
+		wantTitles   []string
+		wantLastPage int
+	}{
+		{"Title ascending", models.Filters{Page: 1, PageSize: 2, Sort: "title"}, []string{"Apple", "Banana"}, 3},
+		{"Title descending", models.Filters{Page: 1, PageSize: 2, Sort: "-title"}, []string{"Elderberry", "Date"}, 3},
+		{"Second page", models.Filters{Page: 2, PageSize: 2, Sort: "title"}, []string{"Cherry", "Date"}, 3},
+		{"Last page", models.Filters{Page: 3, PageSize: 2, Sort: "title"}, []string{"Elderberry"}, 3},
+		{"Past the last page", models.Filters{Page: 4, PageSize: 2, Sort: "title"}, []string{}, 3},
+		{"Newest first", models.Filters{Page: 1, PageSize: 10, Sort: "-created"}, []string{"Elderberry", "Date", "Cherry", "Apple", "Banana"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, metadata, err := m.Latest(tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			titles := []string{}
+			for _, s := range snippets {
+				titles = append(titles, s.Title)
+			}
+			if !slices.Equal(titles, tt.wantTitles) {
+				t.Errorf("got %q; want %q", titles, tt.wantTitles)
+			}
+
+			// only the public, unexpired snippets are counted
+			if metadata.TotalRecords != 5 || metadata.LastPage != tt.wantLastPage {
+				t.Errorf("got %d records on %d pages; want 5 on %d", metadata.TotalRecords, metadata.LastPage, tt.wantLastPage)
+			}
+		})
+	}
+}
+
+func TestSnippetModelByUser(t *testing.T) {
+	m, users := newTestSnippetModel(t)
+
+	if err := users.Insert("Bob", "bob@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	insert(t, m, "Alice's", "mine", models.VisibilityPrivate, time.Hour)
+	if _, _, err := m.Insert("Bob's", []models.File{{Content: "his"}}, models.VisibilityPublic, time.Hour, false, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	snippets, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// users see all of their own snippets, whatever their visibility
+	if len(snippets) != 1 || snippets[0].Title != "Alice's" {
+		t.Errorf("got %d snippets; want only Alice's", len(snippets))
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	m, _ := newTestSnippetModel(t)
+
+	// 12 public snippets mention go, which is more than a page of results. the ones with go in the title rank first
+	for range 12 {
+		insert(t, m, "Snippet", "written in go", models.VisibilityPublic, time.Hour)
+	}
+	insert(t, m, "Go in the title", "nothing else", models.VisibilityPublic, time.Hour)
+	insert(t, m, "Private go", "go go go", models.VisibilityPrivate, time.Hour)
+	insert(t, m, "Expired go", "go go go", models.VisibilityPublic, -time.Hour)
+	insert(t, m, "Unrelated", "rust", models.VisibilityPublic, time.Hour)
+
+	// only the second file of this one mentions elixir
+	_, _, err := m.Insert("Two files", []models.File{{Content: "first"}, {Content: "written in elixir"}}, models.VisibilityPublic, time.Hour, false, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		page         int
+		wantCount    int
+		wantNextPage bool
+		wantFirst    string
+	}{
+		{"First page", "go", 1, models.SearchPageSize, true, "Go in the title"},
+		{"Second page", "go", 2, 3, false, "Snippet"},
+		{"Past the last page", "go", 3, 0, false, ""},
+		{"Page 0 is page 1", "go", 0, models.SearchPageSize, true, "Go in the title"},
+		{"Huge page", "go", math.MaxInt, 0, false, ""},
+		{"Every term must match", "go rust", 1, 0, false, ""},
+		{"Case and punctuation are ignored", "GO!", 1, models.SearchPageSize, true, "Go in the title"},
+		{"No terms", "!!", 1, 0, false, ""},
+		{"Second file", "elixir", 1, 1, false, "Two files"},
+		{"Terms in different files", "first elixir", 1, 1, false, "Two files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, hasNextPage, err := m.Search(tt.query, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snippets) != tt.wantCount || hasNextPage != tt.wantNextPage {
+				t.Errorf("got %d results with another page %t; want %d with another page %t", len(snippets), hasNextPage, tt.wantCount, tt.wantNextPage)
+			}
+			if tt.wantFirst != "" && len(snippets) > 0 && snippets[0].Title != tt.wantFirst {
+				t.Errorf("got %q first; want %q", snippets[0].Title, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	snippets := make([]*models.Snippet, 5)
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   int
+	}{
+		{"First page", 2, 0, 2},
+		{"Last page", 2, 4, 1},
+		{"Past the end", 2, 5, 0},
+		{"Negative offset", 2, -2, 0},
+		{"Overflowed offset", 10, math.MinInt, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(paginate(snippets, tt.limit, tt.offset)); got != tt.want {
+				t.Errorf("got %d snippets; want %d", got, tt.want)
+			}
+		})
+	}
+}
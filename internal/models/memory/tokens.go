@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+// TokenModel is an in-memory implementation of models.TokenModelInterface
+type TokenModel struct {
+	mu     sync.RWMutex
+	tokens map[int]*models.Token
+	nextID int
+}
+
+func NewTokenModel() *TokenModel {
+	return &TokenModel{
+		tokens: make(map[int]*models.Token),
+		nextID: 1,
+	}
+}
+
+func (m *TokenModel) Insert(userID int, name string) (*models.Token, error) {
+	token, err := models.NewToken(userID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token.ID = m.nextID
+	m.nextID++
+
+	// store a copy without the plaintext, just like the database only stores the hash
+	stored := *token
+	stored.Plaintext = ""
+	m.tokens[token.ID] = &stored
+
+	return token, nil
+}
+
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Revoke(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+	delete(m.tokens, id)
+
+	return nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	hash := models.HashToken(plaintext)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tokens {
+		if bytes.Equal(t.Hash, hash) {
+			t.LastUsed = time.Now().UTC()
+			return t.UserID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
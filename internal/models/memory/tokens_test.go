@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+func TestTokenModel(t *testing.T) {
+	m := NewTokenModel()
+
+	alice, err := m.Insert(1, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := m.Insert(2, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the plaintext is handed back once, but never stored
+	if alice.Plaintext == "" {
+		t.Fatal("got no plaintext for a new token")
+	}
+	tokens, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Plaintext != "" {
+		t.Errorf("got %d tokens for the user, with plaintext %q; want 1 without its plaintext", len(tokens), tokens[0].Plaintext)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+		wantID    int
+		wantErr   error
+	}{
+		{"Alice's token", alice.Plaintext, 1, nil},
+		{"Bob's token", bob.Plaintext, 2, nil},
+		{"Unknown token", "not-a-token", 0, models.ErrInvalidCredentials},
+		{"Empty token", "", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(tt.plaintext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Errorf("got user id %d; want %d", id, tt.wantID)
+			}
+		})
+	}
+
+	// users can only revoke their own tokens
+	if err := m.Revoke(bob.ID, 1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("got error %v revoking another user's token; want %v", err, models.ErrNoRecord)
+	}
+	if err := m.Revoke(alice.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(alice.Plaintext); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Errorf("got error %v using a revoked token; want %v", err, models.ErrInvalidCredentials)
+	}
+}
@@ -0,0 +1,107 @@
+// Package memory provides in-memory implementations of the model interfaces in the models package. nothing is persisted, so it is only meant for local development and tests where running a database isn't practical
+package memory
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserModel is an in-memory implementation of models.UserModelInterface. the mutex protects the users map, since handlers run concurrently
+// like the sql model, BcryptCost is the cost used to hash new passwords, or models.DefaultBcryptCost if it is 0
+type UserModel struct {
+	BcryptCost int
+
+	mu     sync.RWMutex
+	users  map[int]*models.User
+	nextID int
+}
+
+func NewUserModel() *UserModel {
+	return &UserModel{
+		users:  make(map[int]*models.User),
+		nextID: 1,
+	}
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	cost := m.BcryptCost
+	if cost == 0 {
+		cost = models.DefaultBcryptCost
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// mimic the users_uc_email unique constraint from the database
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.users[m.nextID] = &models.User{
+		ID:             m.nextID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	}
+	m.nextID++
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	var user *models.User
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			user = u
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.users[id]
+	return ok, nil
+}
+
+// name returns the name of the user with the given id, which the snippet model uses in place of a join
+func (m *UserModel) name(id int) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if u, ok := m.users[id]; ok {
+		return u.Name
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Prateek2593/snippetbox/internal/models"
+)
+
+func TestUserModel(t *testing.T) {
+	m := NewUserModel()
+	m.BcryptCost = 4 // bcrypt.MinCost, so that the tests don't spend their time hashing
+
+	if err := m.Insert("Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	// like the users_uc_email constraint, emails are unique whatever their case
+	if err := m.Insert("Another Alice", "ALICE@example.com", "password"); !errors.Is(err, models.ErrDuplicateEmail) {
+		t.Errorf("got error %v inserting a duplicate email; want %v", err, models.ErrDuplicateEmail)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{"Valid credentials", "alice@example.com", "pa$$word", 1, nil},
+		{"Email in another case", "Alice@Example.com", "pa$$word", 1, nil},
+		{"Wrong password", "alice@example.com", "password", 0, models.ErrInvalidCredentials},
+		{"Unknown email", "bob@example.com", "pa$$word", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Errorf("got id %d; want %d", id, tt.wantID)
+			}
+		})
+	}
+
+	for id, want := range map[int]bool{1: true, 2: false} {
+		exists, err := m.Exists(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != want {
+			t.Errorf("got Exists(%d) = %t; want %t", id, exists, want)
+		}
+	}
+}
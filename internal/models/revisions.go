@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revision is a version of a snippet's title and files. the first revision is created along with the snippet, and every edit which changes the title or files adds another one. Number counts up from 1 for each snippet
+// like a Snippet, Content is that of the first file, and Files is only filled in by Revision()
+type Revision struct {
+	ID        int       `json:"-"`
+	SnippetID int       `json:"-"`
+	Number    int       `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Created   time.Time `json:"created"`
+	Files     []File    `json:"files,omitempty"`
+}
+
+// addRevision records the snippet's title and files as its next revision. it is called inside the transaction which inserts or updates the snippet, so that the revisions always match the snippet. the author of the revision is the snippet's owner, because nobody else can edit it
+func (m *SnippetModel) addRevision(tx *sql.Tx, snippetID int, title string, files []File, created time.Time) error {
+	var number int
+	err := tx.QueryRow(m.Dialect.Rebind(`SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`), snippetID).Scan(&number)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, user_id, created)
+	SELECT id, ?, ?, ?, user_id, ? FROM snippets WHERE id = ?`
+
+	id, err := m.Dialect.insert(tx, stmt, number, title, files[0].Content, created, snippetID)
+	if err != nil {
+		return err
+	}
+
+	return m.insertFiles(tx, "snippet_revision_files", "revision_id", id, files)
+}
+
+// this will return all the revisions of a snippet, newest first
+func (m *SnippetModel) Revisions(snippetID int) ([]*Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.revision, r.title, r.content, r.user_id, u.name, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? ORDER BY r.revision DESC`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		r := &Revision{}
+		err := rows.Scan(&r.ID, &r.SnippetID, &r.Number, &r.Title, &r.Content, &r.UserID, &r.UserName, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// this will return a single revision of a snippet by its number, or ErrNoRecord if there is no such revision
+func (m *SnippetModel) Revision(snippetID int, number int) (*Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.revision, r.title, r.content, r.user_id, u.name, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? AND r.revision = ?`
+
+	r := &Revision{}
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), snippetID, number).Scan(&r.ID, &r.SnippetID, &r.Number, &r.Title, &r.Content, &r.UserID, &r.UserName, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	r.Files, err = m.queryFiles(m.DB, "snippet_revision_files", "revision_id", r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchPageSize is the number of results on each page returned by Search()
+const SearchPageSize = 10
+
+// SearchTerms splits a search query into lower-cased words, dropping any punctuation. it is used to build queries for databases without a forgiving query parser, and to highlight matches
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+}
+
+// this will return a page of the unexpired public snippets matching a full-text search query, most relevant first. pages are numbered from 1, and the boolean result reports whether there are more results after this page
+// each dialect uses its own full-text search: a FULLTEXT index in natural language mode for mysql, a tsvector GIN index for postgres, and an FTS5 table for sqlite. the indexes cover the content of every file in a snippet, not just the first, and titles count for more than content in the ranking wherever the database allows it
+func (m *SnippetModel) Search(query string, page int) ([]*Snippet, bool, error) {
+	var stmt string
+	var args []any
+	now := time.Now().UTC()
+
+	switch m.Dialect {
+	case Postgres:
+		stmt = `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+		FROM snippets s INNER JOIN users u ON u.id = s.user_id,
+		websearch_to_tsquery('english', ?) q
+		WHERE to_tsvector('english', s.title || ' ' || s.search_content) @@ q AND s.expires > ? AND s.visibility = 'public'
+		ORDER BY ts_rank(setweight(to_tsvector('english', s.title), 'A') || setweight(to_tsvector('english', s.search_content), 'B'), q) DESC, s.id DESC
+		LIMIT ? OFFSET ?`
+		args = []any{query, now}
+	case SQLite:
+		// FTS5 has its own query syntax, so we quote every term to stop punctuation in the query being treated as an operator. quoted terms separated by spaces must all match
+		terms := SearchTerms(query)
+		if len(terms) == 0 {
+			return []*Snippet{}, false, nil
+		}
+		for i, t := range terms {
+			terms[i] = `"` + t + `"`
+		}
+
+		stmt = `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+		FROM snippets_ft f INNER JOIN snippets s ON s.id = f.rowid INNER JOIN users u ON u.id = s.user_id
+		WHERE snippets_ft MATCH ? AND s.expires > ? AND s.visibility = 'public'
+		ORDER BY bm25(snippets_ft, 10.0, 1.0), s.id DESC
+		LIMIT ? OFFSET ?`
+		args = []any{strings.Join(terms, " "), now}
+	default:
+		stmt = `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+		FROM snippets s INNER JOIN users u ON u.id = s.user_id
+		WHERE MATCH (s.title, s.search_content) AGAINST (? IN NATURAL LANGUAGE MODE) AND s.expires > ? AND s.visibility = 'public'
+		ORDER BY MATCH (s.title, s.search_content) AGAINST (? IN NATURAL LANGUAGE MODE) DESC, s.id DESC
+		LIMIT ? OFFSET ?`
+		args = []any{query, now, query}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	// fetch one more row than we need, so we know if there is another page without running a separate count query
+	args = append(args, SearchPageSize+1, (page-1)*SearchPageSize)
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(snippets) > SearchPageSize {
+		return snippets[:SearchPageSize], true, nil
+	}
+	return snippets, false, nil
+}
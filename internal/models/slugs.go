@@ -0,0 +1,33 @@
+package models
+
+import (
+	"crypto/rand"
+)
+
+// SlugLength is the number of characters in a new snippet slug. there are 62^10 (about 8*10^17) possible slugs, which is far too many for anyone to find snippets by guessing
+const SlugLength = 10
+
+const slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// NewSlug returns a random base62 slug, which is used in snippet URLs instead of the sequential id
+func NewSlug() (string, error) {
+	slug := make([]byte, 0, SlugLength)
+	randomBytes := make([]byte, SlugLength*2)
+
+	for len(slug) < SlugLength {
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return "", err
+		}
+
+		// bytes of 248 and above are thrown away, because 248 is the largest multiple of 62 that fits in a byte and using them would make some characters more likely than others
+		for _, b := range randomBytes {
+			if b >= 248 || len(slug) == SlugLength {
+				continue
+			}
+			slug = append(slug, slugAlphabet[b%62])
+		}
+	}
+
+	return string(slug), nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSlug(t *testing.T) {
+	const n = 10_000
+	seen := make(map[string]bool, n)
+	counts := map[rune]int{}
+
+	for range n {
+		slug, err := NewSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(slug) != SlugLength {
+			t.Fatalf("got slug %q; want %d characters", slug, SlugLength)
+		}
+		for _, r := range slug {
+			if !strings.ContainsRune(slugAlphabet, r) {
+				t.Fatalf("got slug %q with %q, which isn't in the alphabet", slug, r)
+			}
+			counts[r]++
+		}
+		if seen[slug] {
+			t.Fatalf("got slug %q twice", slug)
+		}
+		seen[slug] = true
+	}
+
+	// every character should turn up about n*SlugLength/62 (about 1600) times. a character which is never or very rarely used means some bytes are being mapped wrongly
+	if len(counts) != len(slugAlphabet) {
+		t.Errorf("got %d different characters; want all %d", len(counts), len(slugAlphabet))
+	}
+	for r, c := range counts {
+		if c < 1000 || c > 2300 {
+			t.Errorf("got %q %d times; want about %d", r, c, n*SlugLength/len(slugAlphabet))
+		}
+	}
+}
@@ -3,61 +3,151 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"math"
 	"time"
 )
 
 // define a Snippet type to hold the data for an individual snippet. notice how the fields of the struct corresponds to the fields in our mysql snippets table
+// Slug is the random string which identifies the snippet in URLs, so that snippets can't be found by counting through the ids
+// Language is the name of the language the content is written in, which decides how it is syntax highlighted
+// Visibility is one of the Visibility constants, and decides who can see the snippet
+// UserID holds the id of the user who created the snippet and UserName their display name, which we join in from the users table
+// ParentID holds the id of the snippet this one was forked from, or 0 if it isn't a fork
+// Files holds every file in the snippet, and is only filled in by Get() and GetBySlug(). the Content and Language fields are those of the first file, which is all the pages listing snippets need
+// BurnAfterReading snippets are deleted the first time someone other than their owner views them
+// the struct tags control how a snippet is encoded by the JSON API
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID               int       `json:"id"`
+	Slug             string    `json:"slug"`
+	Title            string    `json:"title"`
+	Content          string    `json:"content"`
+	Language         string    `json:"language"`
+	Visibility       string    `json:"visibility"`
+	Created          time.Time `json:"created"`
+	Expires          time.Time `json:"expires"`
+	UserID           int       `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	ParentID         int       `json:"parent_id,omitempty"`
+	Files            []File    `json:"files,omitempty"`
+	BurnAfterReading bool      `json:"burn_after_reading"`
 }
 
-// define a SnippetModel type which wraps a sql.DB connection pool
+// Never can be passed to Insert() and Update() as the expiry of a snippet which should be kept until it is deleted. such snippets are stored as expiring at Forever, so that every query which checks the expiry works for them without any special cases
+const Never time.Duration = math.MaxInt64
+
+// Forever is the expiry time of snippets which never expire
+var Forever = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// NeverExpires reports whether the snippet is kept until it is deleted
+func (s *Snippet) NeverExpires() bool {
+	return !s.Expires.Before(Forever)
+}
+
+// expiresAt returns the time at which a snippet created or updated at now expires, if it expires after d
+func expiresAt(now time.Time, d time.Duration) time.Time {
+	if d == Never || now.Add(d).After(Forever) {
+		return Forever
+	}
+	return now.Add(d)
+}
+
+// the visibility of a snippet. public snippets are listed on the home page and in search results, unlisted snippets can be seen by anyone who has the link, and private snippets can only be seen by their owner
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities holds every valid visibility, for validating user input
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// SnippetModelInterface describes the methods the web application needs from a snippet store. both the mysql backed SnippetModel and the in-memory model in the memory package satisfy it
+type SnippetModelInterface interface {
+	Insert(title string, files []File, visibility string, expires time.Duration, burnAfterReading bool, userID int, parentID int) (int, string, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	Update(id int, title string, files []File, visibility string, expires time.Duration, burnAfterReading bool) error
+	Delete(id int) error
+	Burn(id int) error
+	DeleteExpired(limit int) (int, error)
+	Latest(filters Filters) ([]*Snippet, Metadata, error)
+	ByUser(userID int) ([]*Snippet, error)
+	Forks(parentID int) ([]*Snippet, error)
+	Search(query string, page int) ([]*Snippet, bool, error)
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID int, number int) (*Revision, error)
+}
+
+// define a SnippetModel type which wraps a sql.DB connection pool. the Dialect tells it which flavour of SQL the database speaks
 type SnippetModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
 }
 
-// this will insert a new snippet into the database
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// this will insert a new snippet into the database, and return its id and slug
+// the userID is the id of the authenticated user creating the snippet, and is stored alongside it as the owner. parentID is the id of the snippet being forked, or 0 for a brand new snippet
+// files must hold at least one file. the content and language of the first one are stored in the snippets table as well as in snippet_files, along with the content of all of them for searching
+// the snippet expires once the expires duration has passed, or never if it is Never
+func (m *SnippetModel) Insert(title string, files []File, visibility string, expires time.Duration, burnAfterReading bool, userID int, parentID int) (int, string, error) {
+	if len(files) == 0 {
+		return 0, "", ErrNoFiles
+	}
 
 	// writing the sql statement we want to execute. the reason why ? are used is that they indicate placeholder parameters for the data we want to insert, because the data will be provided by the untrusted user input from a form, its a good practice to use placeholder parameters instead of interpolating data in sql query
-	stmt := `INSERT INTO snippets (title, content,created, expires) VALUES (?,?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (slug, title, content, search_content, language, visibility, created, expires, user_id, parent_id, burn_after_reading) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	// DB.Exec() is used for statements which dont return rows(like INSERT and DELETE)
-	// use the Exec() method on the embedded connection pool to execute the statement. the first parameter is the sql sttement, followed by the title, content and expiry value for the placeholder parameter. this methods returns a sql.Result type, which contains some basic information about what happened whent the statement was executed
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
-	}
+	// a parentID of 0 is stored as NULL
+	parent := sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0}
 
-	// use the LastInsertId() method on the result to get the ID of our newly inserted record in the snippets table
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
+	// the created and expires times are calculated in go rather than with database functions like UTC_TIMESTAMP(), because every dialect spells those differently
+	now := time.Now().UTC()
 
-	// the ID returned has the type int64, sw we convert it to an int type before returning
-	return int(id), nil
+	// a new slug clashing with an existing one is very unlikely, but if it happens we just try again with another slug
+	for attempt := 1; ; attempt++ {
+		slug, err := NewSlug()
+		if err != nil {
+			return 0, "", err
+		}
+
+		// the snippet and its first revision are inserted in a transaction, so that we never end up with one but not the other
+		var id int
+		err = inTx(m.DB, func(tx *sql.Tx) error {
+			// use the insert() helper to execute the statement and get the ID of our newly inserted record in the snippets table. it takes care of the differences between the dialects, because postgres doesnt support LastInsertId()
+			id, err = m.Dialect.insert(tx, stmt, slug, title, files[0].Content, searchContent(files), files[0].Language, visibility, now, expiresAt(now, expires), userID, parent, burnAfterReading)
+			if err != nil {
+				return err
+			}
+			err = m.insertFiles(tx, "snippet_files", "snippet_id", id, files)
+			if err != nil {
+				return err
+			}
+			return m.addRevision(tx, id, title, files, now)
+		})
+		if err != nil {
+			if attempt < 3 && m.Dialect.isUniqueViolation(err, "snippets_uc_slug", "snippets.slug") {
+				continue
+			}
+			return 0, "", err
+		}
+
+		return id, slug, nil
+	}
 }
 
-// this will return a specific snippet based on its id
+// this will return a specific snippet based on its id, whatever its visibility. it is up to the caller to check that the current user is allowed to see it
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
 
 	// write the sql statement we want to execute
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires>UTC_TIMESTAMP() AND id = ?`
+	// we join the users table so that the name of the snippet's author is available to the templates
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.id = ?`
 
 	// user the QueryRow() method on the connection pool to execute our sql statement, passing in the untrusted id variable as the value for the placeholder parameter. this returns a pointer to a sql.Row object which holds the result from the database
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRow(m.Dialect.Rebind(stmt), time.Now().UTC(), id)
 
-	// initialize a pointer to a new zeroed Snippet struct
-	s := &Snippet{}
-
-	// use row.Scan() to copy the values from each field in sql.Row to the corresponding field in the Snippet struct. notice that the arguments to row.Scan are *pointers* to the place you want to copy the data into, and the number of arguments must be exactly the same as the number of columns returned by your statement
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// use the scanSnippet() helper to copy the values from each field in sql.Row to the corresponding field in a new Snippet struct
+	s, err := scanSnippet(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// if the query return no rows, then row.Scan() will return a sql.ErrNoRows error. we use the errors.Is() function check for that error specifically and return our own ErrNoRecord error instead
@@ -67,24 +157,220 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	// fetch the snippet's files from the snippet_files table
+	s.Files, err = m.queryFiles(m.DB, "snippet_files", "snippet_id", s.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// if everything went well, return the snippet object
 	return s, nil
 }
 
-// this will return the 10 most recently created snippet
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
+// this will return a specific snippet based on its slug, whatever its visibility. like Get() it returns ErrNoRecord if there is no such unexpired snippet
+func (m *SnippetModel) GetBySlug(slug string) (*Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.slug = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(m.Dialect.Rebind(stmt), time.Now().UTC(), slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	s.Files, err = m.queryFiles(m.DB, "snippet_files", "snippet_id", s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// this will return one page of the unexpired public snippets, sorted and paged according to the filters, along with the metadata describing the page
+func (m *SnippetModel) Latest(filters Filters) ([]*Snippet, Metadata, error) {
+	now := time.Now().UTC()
 
-	//write the sql statement we want to execute
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	// first count all the unexpired snippets, so that we can tell how many pages there are
+	var total int
+	err := m.DB.QueryRow(m.Dialect.Rebind(`SELECT COUNT(*) FROM snippets WHERE expires > ? AND visibility = 'public'`), now).Scan(&total)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	//write the sql statement we want to execute. the ORDER BY clause comes from the filters, which only allow a fixed set of columns, so it is safe to interpolate
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.visibility = 'public' ORDER BY ` + filters.orderBy() + ` LIMIT ? OFFSET ?`
 
 	// use the Query() method on the connection pool to execute the query. this returns a sql.Rows resultset containing the result of our query
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), now, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(total, filters.Page, filters.PageSize), nil
+}
+
+// this will update the title, files, visibility, expiry and burn after reading setting of an existing snippet. the new expiry is counted from the time of the update, and an expires value of 0 leaves the current expiry unchanged
+// the snippet's files are replaced with the new ones as a whole. if the title or any of the files has changed, the new version is recorded as another revision. the update and the revision happen in a transaction, so they succeed or fail together
+func (m *SnippetModel) Update(id int, title string, files []File, visibility string, expires time.Duration, burnAfterReading bool) error {
+	if len(files) == 0 {
+		return ErrNoFiles
+	}
+
+	now := time.Now().UTC()
+
+	return inTx(m.DB, func(tx *sql.Tx) error {
+		// lock the snippet's row before reading anything. otherwise two edits at the same time could both read the old version, and with mysql's repeatable reads both number their revision the same, so that one of them fails on snippet_revisions_uc_revision. the lock makes the second edit wait, and then read what the first one saved
+		var oldTitle string
+		err := tx.QueryRow(m.Dialect.Rebind(`SELECT title FROM snippets WHERE id = ?`+m.Dialect.forUpdate()), id).Scan(&oldTitle)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+
+		oldFiles, err := m.queryFiles(tx, "snippet_files", "snippet_id", id)
+		if err != nil {
+			return err
+		}
+
+		if expires == 0 {
+			stmt := `UPDATE snippets SET title = ?, content = ?, search_content = ?, language = ?, visibility = ?, burn_after_reading = ? WHERE id = ?`
+			_, err = tx.Exec(m.Dialect.Rebind(stmt), title, files[0].Content, searchContent(files), files[0].Language, visibility, burnAfterReading, id)
+		} else {
+			stmt := `UPDATE snippets SET title = ?, content = ?, search_content = ?, language = ?, visibility = ?, burn_after_reading = ?, expires = ? WHERE id = ?`
+			_, err = tx.Exec(m.Dialect.Rebind(stmt), title, files[0].Content, searchContent(files), files[0].Language, visibility, burnAfterReading, expiresAt(now, expires), id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if title == oldTitle && filesEqual(files, oldFiles) {
+			return nil
+		}
+
+		_, err = tx.Exec(m.Dialect.Rebind(`DELETE FROM snippet_files WHERE snippet_id = ?`), id)
+		if err != nil {
+			return err
+		}
+		err = m.insertFiles(tx, "snippet_files", "snippet_id", id, files)
+		if err != nil {
+			return err
+		}
+		return m.addRevision(tx, id, title, files, now)
+	})
+}
+
+// this will delete a specific snippet based on its id
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	result, err := m.DB.Exec(m.Dialect.Rebind(stmt), id)
+	if err != nil {
+		return err
+	}
+
+	// if no rows were affected the snippet doesnt exist, so we return our ErrNoRecord error
+	return checkRowsAffected(result)
+}
+
+// this will delete a snippet which burns after reading, as it is viewed. only one call can delete the snippet, so if it is viewed by several people at the same time Burn() returns ErrNoRecord for all but one of them, and only that one should be shown it
+func (m *SnippetModel) Burn(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND burn_after_reading = ?`
+
+	result, err := m.DB.Exec(m.Dialect.Rebind(stmt), id, true)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// this will delete up to limit snippets which have expired, and return how many were deleted. the other queries already ignore expired snippets, so this only frees up the space they take. their files and revisions go with them, and forks of them are kept just like when a snippet is deleted by its owner
+// deleting in batches keeps each statement short, so that it doesn't hold locks on the table for long when a lot of snippets expire at once
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	// mysql can't use LIMIT in a subquery of IN, but unlike the others it does allow LIMIT on a DELETE
+	stmt := `DELETE FROM snippets WHERE id IN (SELECT id FROM snippets WHERE expires <= ? ORDER BY expires LIMIT ?)`
+	if m.Dialect != Postgres && m.Dialect != SQLite {
+		stmt = `DELETE FROM snippets WHERE expires <= ? ORDER BY expires LIMIT ?`
+	}
+
+	result, err := m.DB.Exec(m.Dialect.Rebind(stmt), time.Now().UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// this will return all the unexpired snippets created by a specific user, whatever their visibility, newest first
+func (m *SnippetModel) ByUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.user_id = ? ORDER BY s.id DESC`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), time.Now().UTC(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet reads a single row into a new Snippet. the row must contain the columns selected by every snippet query in this package, in the same order
+func scanSnippet(row rowScanner) (*Snippet, error) {
+	s := &Snippet{}
+
+	// parent_id is NULL for snippets which aren't forks, so we scan it into a sql.NullInt64 and use 0 to mean no parent
+	var parentID sql.NullInt64
+
+	// notice that the arguments to Scan are *pointers* to the place you want to copy the data into, and the number of arguments must be exactly the same as the number of columns returned by the SELECT statement
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.Created, &s.Expires, &s.UserID, &s.UserName, &parentID, &s.BurnAfterReading)
+	if err != nil {
+		return nil, err
+	}
+	s.ParentID = int(parentID.Int64)
+
+	return s, nil
+}
+
+// this will return the unexpired public forks of a snippet, newest first. forks which are unlisted or private aren't included, so that they can't be discovered through their parent
+func (m *SnippetModel) Forks(parentID int) ([]*Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.content, s.language, s.visibility, s.created, s.expires, s.user_id, u.name, s.parent_id, s.burn_after_reading
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.expires > ? AND s.parent_id = ? AND s.visibility = 'public' ORDER BY s.id DESC`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), time.Now().UTC(), parentID)
 	if err != nil {
 		return nil, err
 	}
 
-	// we defer rows.Close() to ensure the sql.Rows resultset is always properlt closed before Latest() method returns. this defer statement should come *after* you check for an error from the Query() method. otherwise, if Query() returns an error, you'll get a panic trying to close a nil result set
+	return scanSnippets(rows)
+}
+
+// scanSnippets reads every row of a snippets resultset into a slice, closing the resultset once it is done
+func scanSnippets(rows *sql.Rows) ([]*Snippet, error) {
+
+	// we defer rows.Close() to ensure the sql.Rows resultset is always properlt closed before this function returns. this defer statement should come *after* you check for an error from the Query() method. otherwise, if Query() returns an error, you'll get a panic trying to close a nil result set
 	defer rows.Close()
 
 	// initialize an empty slice of pointers to Snippet structs
@@ -92,11 +378,8 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	// use rows.Next to iterate through the rows in the resultset. this prepares the first (and then each subsequent) row to be acted on by the rows.Scan() method. if iteration over all the rows completes then the resultset automatically closes itself and frees up the underlying database connection.
 	for rows.Next() {
-		// create a pointer to a new zeroed snippet struct
-		s := &Snippet{}
-
-		// use rows.Scan() to copy the values from each field in the current row into the corresponding field in the Snippet struct. notice that the arguments to rows.Scan are *pointers* to the place you want to copy the data into, and the number of arguments must be exactly the same as the number of columns returned by the SELECT statement in the sql statement. if there's an error during this scan, we return the error immediately, so we don't continue scanning the
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// use the scanSnippet() helper to copy the values from each field in the current row into a new Snippet struct. if there's an error during this scan, we return the error immediately, so we don't continue scanning the rest of the rows
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -104,10 +387,38 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		snippets = append(snippets, s)
 	}
 	// when the rows.Next() loop has finished, we call rows.Err() to retrieve any error that was encountered during the iteration, its important to call this - dont assume that a successful iteration was completed over the whole resultset
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
 	// if everything went well, return the slice of pointers to Snippet structs
 	return snippets, nil
 }
+
+// inTx runs fn inside a transaction, which is committed if fn returns nil and rolled back otherwise
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkRowsAffected returns ErrNoRecord if the statement behind result didnt touch any rows
+func checkRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
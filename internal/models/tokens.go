@@ -0,0 +1,143 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+// TokenPrefix is prepended to every plaintext token, so that tokens are easy to recognise (for example by secret scanners) if they leak
+const TokenPrefix = "sbx_"
+
+// Token is a personal access token which lets non-browser clients use the JSON API on behalf of a user. only a SHA-256 hash of the token is stored, so the plaintext is only available in the value returned by Insert()
+type Token struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Name      string    `json:"name"`
+	Hash      []byte    `json:"-"`
+	Created   time.Time `json:"created"`
+	LastUsed  time.Time `json:"last_used"` // the zero time means the token has never been used
+	Plaintext string    `json:"-"`
+}
+
+// TokenModelInterface describes the methods the web application needs from a token store
+type TokenModelInterface interface {
+	Insert(userID int, name string) (*Token, error)
+	ByUser(userID int) ([]*Token, error)
+	Revoke(id, userID int) error
+	Authenticate(plaintext string) (int, error)
+}
+
+// NewToken generates a token for a user with a random plaintext value and its hash. the plaintext has 160 bits of entropy, encoded in base32 so it is safe to use in headers
+func NewToken(userID int, name string) (*Token, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := TokenPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	return &Token{
+		UserID:    userID,
+		Name:      name,
+		Hash:      HashToken(plaintext),
+		Created:   time.Now().UTC(),
+		Plaintext: plaintext,
+	}, nil
+}
+
+// HashToken returns the SHA-256 hash of a plaintext token, which is what we store and look tokens up by. a fast hash is fine here (unlike for passwords) because the tokens are long and random
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// this will create a new named token for a user. the returned token has its Plaintext field set, which must be shown to the user now because it can't be recovered later
+func (m *TokenModel) Insert(userID int, name string) (*Token, error) {
+	token, err := NewToken(userID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, created) VALUES (?, ?, ?, ?)`
+
+	token.ID, err = m.Dialect.insert(m.DB, stmt, token.UserID, token.Name, token.Hash, token.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// this will return all the tokens belonging to a user, newest first
+func (m *TokenModel) ByUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, hash, created, last_used FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(m.Dialect.Rebind(stmt), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+	for rows.Next() {
+		t := &Token{}
+		var lastUsed sql.NullTime
+		err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// this will delete a token, but only if it belongs to the given user. it returns ErrNoRecord otherwise, so users can't revoke each other's tokens
+func (m *TokenModel) Revoke(id, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(m.Dialect.Rebind(stmt), id, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// this will return the id of the user a plaintext token belongs to, and record that the token has been used. if there is no such token it returns ErrInvalidCredentials
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	hash := HashToken(plaintext)
+
+	var userID int
+	stmt := `SELECT user_id FROM tokens WHERE hash = ?`
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), hash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	stmt = `UPDATE tokens SET last_used = ? WHERE hash = ?`
+	_, err = m.DB.Exec(m.Dialect.Rebind(stmt), time.Now().UTC(), hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -3,10 +3,8 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,28 +16,43 @@ type User struct {
 	Created        time.Time
 }
 
+// UserModelInterface describes the methods the web application needs from a user store
+type UserModelInterface interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+}
+
+// DefaultBcryptCost is the bcrypt cost used to hash passwords when a user model doesn't set one. each step up doubles the time it takes to hash (and to guess) a password
+const DefaultBcryptCost = 12
+
+// the BcryptCost is the cost used to hash new passwords, or DefaultBcryptCost if it is 0. changing it doesn't affect passwords which are already hashed, because the cost is stored in the hash
 type UserModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	Dialect    Dialect
+	BcryptCost int
 }
 
 func (m *UserModel) Insert(name, email, password string) error {
+	cost := m.BcryptCost
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+
 	// create a bcrypt hash of the plain text password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users(name, email, hashed_password, created) VALUES(?,?,?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users(name, email, hashed_password, created) VALUES(?,?,?,?)`
 
 	// Use the Exec() method to insert the user details and hased password into the users table
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.Exec(m.Dialect.Rebind(stmt), name, email, string(hashedPassword), time.Now().UTC())
 	if err != nil {
-		// if this returns an error, we use the errors.As() function to check whether the error has the type *mysql.MySQLError. if it does, the error will be assigned to the mySQLError variable. we can then check whether or not the error relates to our users_uc_email key by checking if the error code equals 1062 and the contents of the error message string. of it does, we return an ErrDuplicateEmail error
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		// if this returns an error, we check whether it was caused by our users_uc_email unique constraint on the email column. each database reports this differently, so the dialect does the work of inspecting the error. if it was, we return an ErrDuplicateEmail error
+		if m.Dialect.isUniqueViolation(err, "users_uc_email", "users.email") {
+			return ErrDuplicateEmail
 		}
 		return err
 	}
@@ -53,7 +66,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 
 	smtt := "SELECT id, hashed_password FROM users WHERE email=?"
 
-	err := m.DB.QueryRow(smtt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(m.Dialect.Rebind(smtt), email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -79,7 +92,7 @@ func (m *UserModel) Exists(id int) (bool, error) {
 
 	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRow(m.Dialect.Rebind(stmt), id).Scan(&exists)
 
 	return exists, err
 }
@@ -0,0 +1,184 @@
+// Package syntax turns snippet content into syntax highlighted HTML using chroma, and guesses the language of a snippet when the user doesnt choose one
+package syntax
+
+import (
+	"encoding/json"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// PlainText is the language used for snippets which aren't code, or whose language couldn't be detected. it is never highlighted
+const PlainText = "plaintext"
+
+// Language is one of the languages a snippet can be written in. Name is what we store in the database and is also the name chroma knows the language by, and Label is what we show to users
+type Language struct {
+	Name  string
+	Label string
+}
+
+// Languages holds the supported languages, in the order they are listed in the create and edit forms
+var Languages = []Language{
+	{PlainText, "Plain text"},
+	{"bash", "Bash"},
+	{"c", "C"},
+	{"cpp", "C++"},
+	{"csharp", "C#"},
+	{"css", "CSS"},
+	{"diff", "Diff"},
+	{"docker", "Dockerfile"},
+	{"go", "Go"},
+	{"haskell", "Haskell"},
+	{"html", "HTML"},
+	{"java", "Java"},
+	{"javascript", "JavaScript"},
+	{"json", "JSON"},
+	{"kotlin", "Kotlin"},
+	{"lua", "Lua"},
+	{"makefile", "Makefile"},
+	{"markdown", "Markdown"},
+	{"php", "PHP"},
+	{"python", "Python"},
+	{"ruby", "Ruby"},
+	{"rust", "Rust"},
+	{"sql", "SQL"},
+	{"swift", "Swift"},
+	{"toml", "TOML"},
+	{"typescript", "TypeScript"},
+	{"xml", "XML"},
+	{"yaml", "YAML"},
+}
+
+// Names returns the names of all the supported languages, which is handy for validating user input
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Label returns the label for a language name, falling back to the name itself for unknown languages
+func Label(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return name
+}
+
+// supported returns the name of the supported language which a chroma lexer is for, or an empty string if the lexer isnt for one of them
+func supported(lexer chroma.Lexer) string {
+	if lexer == nil {
+		return ""
+	}
+
+	for _, l := range Languages {
+		if l.Name == PlainText {
+			continue
+		}
+		if lexers.Get(l.Name) == lexer {
+			return l.Name
+		}
+	}
+	return variants[lexer.Config().Name]
+}
+
+// variants maps the chroma lexers for dialects of a supported language onto that language. for example chroma matches .sql files with its MySQL lexer
+var variants = map[string]string{
+	"MySQL":                  "sql",
+	"PostgreSQL SQL dialect": "sql",
+	"PL/pgSQL":               "sql",
+	"Bash Session":           "bash",
+	"react":                  "javascript",
+}
+
+// hints are patterns which give away the language of a snippet. chroma can only analyse the content of a handful of languages, so we check these first. the order matters, because the first match wins
+var hints = []struct {
+	rx   *regexp.Regexp
+	name string
+}{
+	{regexp.MustCompile(`^#!.*\b(ba|z)?sh\b`), "bash"},
+	{regexp.MustCompile(`^#!.*\bpython`), "python"},
+	{regexp.MustCompile(`^#!.*\b(node|deno)\b`), "javascript"},
+	{regexp.MustCompile(`^#!.*\bruby\b`), "ruby"},
+	{regexp.MustCompile(`^<\?php`), "php"},
+	{regexp.MustCompile(`(?m)^package \w+\s*$[\s\S]*\bfunc\b`), "go"},
+	{regexp.MustCompile(`(?m)^\s*fn \w+\(|\blet mut\b|\bimpl\b.*\{`), "rust"},
+	{regexp.MustCompile(`(?m)^\s*(def \w+\(.*\)\s*(->.*)?:|from \w+(\.\w+)* import |import \w+$|if __name__ == )`), "python"},
+	{regexp.MustCompile(`(?m)^\s*(public|private|protected)?\s*(static\s+)?(class|interface) \w+|\bSystem\.out\.print`), "java"},
+	{regexp.MustCompile(`(?m)^#include\s*<\w+(\.h)?>[\s\S]*\b(std::|namespace|template\s*<)`), "cpp"},
+	{regexp.MustCompile(`(?m)^#include\s*<\w+>`), "c"},
+	{regexp.MustCompile(`(?m)^\s*(const|let|var) \w+\s*=|\bfunction\s*\w*\(|=>\s*\{|console\.log\(`), "javascript"},
+	{regexp.MustCompile(`(?is)^\s*(SELECT\b.+\bFROM|INSERT\s+INTO|UPDATE\s+\w+\s+SET|DELETE\s+FROM|CREATE\s+(TABLE|INDEX|VIEW))\b`), "sql"},
+	{regexp.MustCompile(`(?i)^\s*(<!doctype html|<html)`), "html"},
+	{regexp.MustCompile(`^\s*<\?xml`), "xml"},
+	{regexp.MustCompile(`^(diff --git|--- \S+\n\+\+\+ )`), "diff"},
+	{regexp.MustCompile(`(?m)^FROM \S+(:\S+)?\s*$[\s\S]*^(RUN|CMD|COPY|ENTRYPOINT) `), "docker"},
+	{regexp.MustCompile(`(?m)^(echo|export|sudo|cd|apt-get|apt|brew|curl|git) `), "bash"},
+}
+
+// Detect guesses the language of a snippet. if the title looks like a filename (e.g. main.go or Makefile) it decides, otherwise we look at the content, first for the hints above, then with chroma's own analysers, and finally by checking whether it is valid JSON. anything we can't recognise is treated as plain text
+func Detect(title, content string) string {
+	title = strings.TrimSpace(title)
+	if title != "" && !strings.ContainsAny(title, " \t") {
+		if name := supported(lexers.Match(title)); name != "" {
+			return name
+		}
+	}
+
+	for _, h := range hints {
+		if h.rx.MatchString(content) {
+			return h.name
+		}
+	}
+
+	if name := supported(lexers.Analyse(content)); name != "" {
+		return name
+	}
+
+	if trimmed := strings.TrimSpace(content); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if json.Valid([]byte(trimmed)) {
+			return "json"
+		}
+	}
+
+	return PlainText
+}
+
+// the formatter writes CSS classes rather than inline styles, because our Content-Security-Policy doesnt allow inline styles. the matching stylesheet is ui/static/css/chroma.css, which can be regenerated with CSS()
+var formatter = html.New(html.WithClasses(true), html.PreventSurroundingPre(true))
+
+// HTML returns the content syntax highlighted as the given language, ready to be placed inside a <pre class='chroma'><code> element. plain text and unknown languages are just HTML escaped
+func HTML(content, language string) template.HTML {
+	lexer := lexers.Get(language)
+	if language == PlainText || lexer == nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+
+	var b strings.Builder
+	err = formatter.Format(&b, styles.Get("github"), iterator)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+
+	return template.HTML(b.String())
+}
+
+// CSS returns the stylesheet for the classes used by HTML()
+func CSS() (string, error) {
+	var b strings.Builder
+	err := formatter.WriteCSS(&b, styles.Get("github"))
+	return b.String(), err
+}
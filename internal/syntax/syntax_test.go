@@ -0,0 +1,72 @@
+package syntax
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    string
+	}{
+		{"Go content", "", "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n", "go"},
+		{"Python content", "", "def greet(name):\n    print(name)\n", "python"},
+		{"Python imports", "", "from os import path\nimport sys\n", "python"},
+		{"Shell shebang", "", "#!/bin/bash\nls -la\n", "bash"},
+		{"Shell commands", "", "cd /tmp\ngit clone https://example.com/repo.git\n", "bash"},
+		{"Go filename", "main.go", "x := 1", "go"},
+		{"Python filename", "script.py", "x = 1", "python"},
+		{"Shell filename", "deploy.sh", "x=1", "bash"},
+		{"Filename beats content", "notes.py", "package main\n\nfunc main() {}\n", "python"},
+		{"Title with spaces is not a filename", "my notes.go", "just some words", PlainText},
+		{"Unknown filename", "notes.unknownext", "just some words", PlainText},
+		{"Plain text", "Shopping list", "eggs\nmilk\nbread\n", PlainText},
+		{"Empty", "", "", PlainText},
+		{"JSON", "", `{"name": "alice", "tags": [1, 2]}`, "json"},
+		{"Invalid JSON", "", `{"name": }`, PlainText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.title, tt.content); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// spans matches the tags the formatter wraps around each highlighted token
+var spans = regexp.MustCompile(`<span class="\w+">|</span>`)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+	}{
+		{"Plain text", "<script>alert('hi')</script>", PlainText},
+		{"Unknown language", "<script>alert('hi')</script>", "nosuchlanguage"},
+		{"HTML", "<script>alert('hi')</script>", "html"},
+		{"Go string", `fmt.Println("<script>alert('hi')</script>")`, "go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(HTML(tt.content, tt.language))
+
+			// once the highlighting spans are taken away, whatever the language, there must be no markup left from the content, and unescaping what's left must give back the content
+			text := spans.ReplaceAllString(got, "")
+			if strings.Contains(text, "<") {
+				t.Errorf("got unescaped markup in %q", got)
+			}
+			if unescaped := html.UnescapeString(text); unescaped != tt.content {
+				t.Errorf("got text %q; want %q", unescaped, tt.content)
+			}
+		})
+	}
+}
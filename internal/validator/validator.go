@@ -66,6 +66,16 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	return false
 }
 
+// PermittedValue() returns true if a value is in a list of permitted values of any comparable type
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+	return false
+}
+
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
 }
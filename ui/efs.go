@@ -0,0 +1,8 @@
+package ui
+
+import "embed"
+
+// Files holds the HTML templates and static files, which are built into the binary so that it can be run from any directory, or copied somewhere on its own. the paths inside it are relative to this directory, like "html/base.tmpl" and "static/css/main.css"
+//
+//go:embed "html" "static"
+var Files embed.FS